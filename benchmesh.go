@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asiekierka/reimagined-disco/mesh"
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// BENCH_MESH_PASSES is the number of times each mesher remeshes the
+// chunks around the player in benchmarkMesh.
+const BENCH_MESH_PASSES = 8
+
+// nullAtlas hands out empty textures, letting the meshers run without a
+// texture sheet.
+type nullAtlas struct{}
+
+func (nullAtlas) Texture(name string) world.Texture {
+	return world.Texture{MaxU: 1, MaxV: 1}
+}
+
+// benchmarkMesh meshes snapshots of the columns of chunks within radius of
+// the player, as the renderer does, with the per-face and the greedy
+// mesher, with and without smooth lighting, printing the time taken and
+// quads made, then reports how many of those chunks can be seen from the
+// player's.
+func benchmarkMesh(radius int) {
+	pos := player.Pos.BlockPos()
+	pcx := pos.X >> world.CHUNK_SHIFT
+	pcz := pos.Z >> world.CHUNK_SHIFT
+	var chunks []world.Position
+	for y := 0; y < world.MAP_H>>world.CHUNK_SHIFT; y++ {
+		for z := -radius; z <= radius; z++ {
+			for x := -radius; x <= radius; x++ {
+				p := world.Position{X: pcx + x, Y: y, Z: pcz + z}
+				w.LoadChunk(p)
+				chunks = append(chunks, p)
+			}
+		}
+	}
+
+	builders := []struct {
+		name string
+		opts mesh.Options
+	}{
+		{"per-face", mesh.Options{}},
+		{"greedy", mesh.Options{Greedy: true}},
+		{"per-face, smooth", mesh.Options{Smooth: true}},
+		{"greedy, smooth", mesh.Options{Greedy: true, Smooth: true}},
+	}
+	for _, b := range builders {
+		var m mesh.Layers
+		quads := 0
+		t := time.Now()
+		for i := 0; i < BENCH_MESH_PASSES; i++ {
+			quads = 0
+			for _, p := range chunks {
+				m.Reset()
+				mesh.Build(world.TakeSnapshot(&w, p), nullAtlas{}, p, &m, b.opts)
+				quads += m.Quads()
+			}
+		}
+		perChunk := time.Since(t) / time.Duration(BENCH_MESH_PASSES*len(chunks))
+		fmt.Printf("%s: %d chunks, %d quads, %v per chunk\n", b.name, len(chunks), quads, perChunk)
+	}
+
+	visibility := make(map[world.Position]mesh.Visibility, len(chunks))
+	t := time.Now()
+	for _, p := range chunks {
+		visibility[p] = mesh.ChunkVisibility(world.TakeSnapshot(&w, p), p)
+	}
+	perChunk := time.Since(t) / time.Duration(len(chunks))
+	start := world.Position{X: pcx, Y: player.Pos.Translate(world.Vec3{0, world.EYE_HEIGHT, 0}).BlockPos().Y >> world.CHUNK_SHIFT, Z: pcz}
+	visible := mesh.VisibleChunks(start, func(p world.Position) mesh.Visibility {
+		if v, ok := visibility[p]; ok {
+			return v
+		}
+		return mesh.VISIBLE_ALL
+	}, func(p world.Position) bool {
+		return p.X >= pcx-radius && p.X <= pcx+radius && p.Z >= pcz-radius && p.Z <= pcz+radius &&
+			p.Y >= 0 && p.Y <= start.Y
+	})
+	// leave out the chunks above the world
+	n := 0
+	for _, p := range visible {
+		if _, ok := visibility[p]; ok {
+			n++
+		}
+	}
+	fmt.Printf("visibility: %d of %d chunks visible, %v per chunk\n", n, len(chunks), perChunk)
+}
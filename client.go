@@ -0,0 +1,197 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/asiekierka/reimagined-disco/render"
+	"github.com/go-gl/glfw/v3.1/glfw"
+)
+
+const WINDOW_TITLE = "GM-M1-142, strona 12"
+
+var (
+	rend   render.Render
+	lastMx float64
+	lastMy float64
+)
+
+func onMouse(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mod glfw.ModifierKey) {
+	if action == glfw.Press {
+		if button == glfw.MouseButtonLeft {
+			breakBlock()
+		} else if button == glfw.MouseButtonRight {
+			placeBlock()
+		}
+	}
+}
+
+func onResize(w *glfw.Window, width int, height int) {
+	rend.Resize(int32(width), int32(height))
+}
+
+func onMove(w *glfw.Window, x float64, y float64) {
+	player.Yaw += float32((x - lastMx) / 1000)
+	player.Pitch += float32((y - lastMy) / 1000)
+	if player.Pitch < -(math.Pi / 2) {
+		player.Pitch = -(math.Pi / 2)
+	} else if player.Pitch > (math.Pi / 2) {
+		player.Pitch = (math.Pi / 2)
+	}
+	lastMx = x
+	lastMy = y
+}
+
+func onKey(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if key == glfw.KeyW {
+		if action == glfw.Release {
+			movementX = 0.0
+		} else {
+			movementX = 0.12
+		}
+	}
+	if key == glfw.KeyS {
+		if action == glfw.Release {
+			movementX = 0.0
+		} else {
+			movementX = -0.12
+		}
+	}
+	if key == glfw.KeyA {
+		if action == glfw.Release {
+			movementZ = 0.0
+		} else {
+			movementZ = -0.12
+		}
+	}
+	if key == glfw.KeyD {
+		if action == glfw.Release {
+			movementZ = 0.0
+		} else {
+			movementZ = 0.12
+		}
+	}
+	if key == glfw.KeySpace {
+		if action == glfw.Press {
+			player.Jump()
+		}
+		player.Swim = action != glfw.Release
+	}
+	if key >= glfw.Key1 && key <= glfw.Key9 && action == glfw.Press {
+		if i := int(key - glfw.Key1); i < len(placeable) {
+			selected = i
+			fmt.Printf("Selected %s\n", placeable[i])
+		}
+	}
+	if (key == glfw.KeyMinus || key == glfw.KeyEqual) && action != glfw.Release {
+		if key == glfw.KeyMinus {
+			rend.SetViewDistance(rend.ViewDistance() - 1)
+		} else {
+			rend.SetViewDistance(rend.ViewDistance() + 1)
+		}
+		fmt.Printf("View distance: %d chunks\n", rend.ViewDistance())
+	}
+	if key == glfw.KeyL {
+		if action == glfw.Press {
+			rend.SetSmooth(!rend.Smooth())
+			fmt.Printf("Smooth lighting: %v\n", rend.Smooth())
+		}
+	}
+	if key == glfw.KeyG {
+		if action == glfw.Press {
+			if rend.SetGreedy(!rend.Greedy()) {
+				fmt.Printf("Greedy meshing: %v\n", rend.Greedy())
+			}
+		}
+	}
+}
+
+// createWindow opens the game window, asking for an OpenGL 3.3 core
+// context if core is set and falling back to OpenGL 2.1. It reports
+// whether the context is a core one. The context is made current.
+func createWindow(core bool) (*glfw.Window, bool, error) {
+	if core {
+		glfw.WindowHint(glfw.ContextVersionMajor, 3)
+		glfw.WindowHint(glfw.ContextVersionMinor, 3)
+		glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
+		glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+		window, err := glfw.CreateWindow(800, 600, WINDOW_TITLE, nil, nil)
+		if err == nil {
+			window.MakeContextCurrent()
+			return window, true, nil
+		}
+		fmt.Printf("OpenGL 3.3 core context unavailable, falling back: %v\n", err)
+		glfw.DefaultWindowHints()
+	}
+	glfw.WindowHint(glfw.ContextVersionMajor, 2)
+	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	window, err := glfw.CreateWindow(800, 600, WINDOW_TITLE, nil, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	window.MakeContextCurrent()
+	return window, false, nil
+}
+
+func runClient() error {
+	fmt.Printf("Loading...\n")
+	if err := glfw.Init(); err != nil {
+		return fmt.Errorf("failed to initialize glfw: %w", err)
+	}
+	defer glfw.Terminate()
+
+	window, core, err := createWindow(*glMode == "core")
+	if err != nil {
+		return err
+	}
+	if err := rend.Init(800, 600, *debugtextures, core); err != nil {
+		if !core {
+			return fmt.Errorf("failed to initialize renderer: %w", err)
+		}
+		fmt.Printf("OpenGL 3.3 renderer unavailable, falling back: %v\n", err)
+		window.Destroy()
+		if window, _, err = createWindow(false); err != nil {
+			return err
+		}
+		if err := rend.Init(800, 600, *debugtextures, false); err != nil {
+			return fmt.Errorf("failed to initialize renderer: %w", err)
+		}
+	}
+	defer rend.Deinit()
+	window.SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
+	window.SetKeyCallback(onKey)
+	window.SetCursorPosCallback(onMove)
+	window.SetFramebufferSizeCallback(onResize)
+	window.SetMouseButtonCallback(onMouse)
+
+	//glfw.SwapInterval(0)
+
+	rend.SetGreedy(*greedy)
+	rend.SetSmooth(*smooth)
+	rend.SetViewDistance(*viewdistance)
+
+	w.RegisterRenderListener(&rend)
+
+	lastTitle := time.Now()
+	for !window.ShouldClose() {
+		t := time.Now()
+		rend.Render(&player, &w)
+		window.SwapBuffers()
+		glfw.PollEvents()
+		nanoTime := time.Since(t)
+		fpsNow := float64(1000000000) / float64(nanoTime)
+		fps.Push(fpsNow)
+		//fmt.Printf("%.2f (%.2f) [%.2f %.2f %.2f]\n", fps.Get(), nanoTime, player.Pos[0], player.Pos[1], player.Pos[2])
+		if time.Since(lastTitle) >= time.Second {
+			drawn, culled, occluded := rend.ChunkStats()
+			window.SetTitle(fmt.Sprintf("%s (%.0f fps, %d chunks drawn, %d culled, %d occluded)", WINDOW_TITLE, fps.Get(), drawn, culled, occluded))
+			lastTitle = time.Now()
+		}
+
+		update(nanoTime, rend.ViewDistance())
+	}
+	return nil
+}
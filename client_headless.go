@@ -0,0 +1,11 @@
+//go:build headless
+
+package main
+
+import (
+	"errors"
+)
+
+func runClient() error {
+	return errors.New("built without graphics support; run with -headless")
+}
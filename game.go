@@ -5,26 +5,28 @@ import (
 	"fmt"
 	_ "image/png"
 	"log"
-	"math"
 	"os"
 	"runtime"
 	"runtime/pprof"
 	"time"
 
-	"github.com/barnex/fmath"
-	"github.com/go-gl/glfw/v3.1/glfw"
+	"github.com/asiekierka/reimagined-disco/world"
 )
 
 var cpuprofile = flag.Bool("cpuprofile", false, "write cpu profile to file")
 var heapprofile = flag.Bool("heapprofile", false, "write heap profile to file")
 var debugtextures = flag.Bool("debugtextures", false, "write texture sheet to file")
-
-type Player struct {
-	pos Vec3
-	gravity float32
-	yaw   float32
-	pitch float32
-}
+var worlddir = flag.String("world", "world", "directory to load the world from and save it to")
+var generator = flag.String("generator", "simplex", "terrain generator for new worlds (simplex, flat, void)")
+var seed = flag.String("seed", "", "seed for new worlds; random if empty")
+var preset = flag.String("preset", "", "generator options for new worlds, such as the flat generator's layers")
+var headless = flag.Bool("headless", false, "run the world without opening a window")
+var ticks = flag.Int("ticks", 0, "in headless mode, stop after this many ticks (0 = run forever)")
+var greedy = flag.Bool("greedy", false, "merge block faces with the greedy mesher (toggle in game with G)")
+var smooth = flag.Bool("smooth", true, "smooth lighting and ambient occlusion (toggle in game with L)")
+var viewdistance = flag.Int("viewdistance", 10, "radius, in chunks, of the area drawn around the player (change in game with - and =)")
+var glMode = flag.String("gl", "core", "renderer to use (core for OpenGL 3.3, legacy for OpenGL 2.1)")
+var benchmesh = flag.Bool("benchmesh", false, "compare the per-face and greedy meshers on the chunks around the player, then exit")
 
 type Average struct {
 	data []float64
@@ -53,123 +55,69 @@ func (a Average) Get() float64 {
 
 var (
 	fps       Average
-	player    Player
+	player    world.Player
 	movementX float32
 	movementZ float32
-	render    Render
-	lastMx    float64
-	lastMy    float64
+	selected  int
 )
 
-const DEG_RAD = math.Pi / 180
-
-func onMouse(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mod glfw.ModifierKey) {
-	if action == glfw.Press {
-		if button == glfw.MouseButtonLeft {
-			breakBlock()
-		} else if button == glfw.MouseButtonRight {
-			placeBlock()
-		}
-	}
-}
-
-func onResize(w *glfw.Window, width int, height int) {
-	render.Resize(int32(width), int32(height))
-}
-
-func onMove(w *glfw.Window, x float64, y float64) {
-	player.yaw += float32((x - lastMx) / 1000)
-	player.pitch += float32((y - lastMy) / 1000)
-	if player.pitch < -(math.Pi / 2) {
-		player.pitch = -(math.Pi / 2)
-	} else if player.pitch > (math.Pi / 2) {
-		player.pitch = (math.Pi / 2)
-	}
-	lastMx = x
-	lastMy = y
-}
-
-func (player Player) GetHoverCoords(w World) (Position, bool) {
-	stepX := -fmath.Sin(-player.yaw) * fmath.Cos(player.pitch) * 0.2
-	stepY := -fmath.Sin(player.pitch) * 0.2
- 	stepZ := -fmath.Cos(-player.yaw) * fmath.Cos(player.pitch) * 0.2
-	bX := player.pos[0]
-	bY := player.pos[1] + EYE_HEIGHT
-	bZ := player.pos[2]
-	for stepCount := 100; stepCount > 0; stepCount-- {
-		if w.GetBlock(int(bX), int(bY), int(bZ)) != nil {
-			return Position{int(bX), int(bY), int(bZ)}, true
-		} else {
-			bX += stepX
-			bY += stepY
-			bZ += stepZ
-		}		
-	}
-	return Position{}, false
-}
+// placeable lists the blocks the player can place, selected with the
+// number keys.
+var placeable = []string{"gold_block", "lamp", "stone_slab", "log", "sand", "sandstone", "glass", "water", "lava"}
 
 func breakBlock() {
 	if pos, exists := player.GetHoverCoords(&w); exists {
-		w.SetBlock(pos.x, pos.y, pos.z, nil)
+		w.SetBlock(pos.X, pos.Y, pos.Z, nil)
 	}
 }
 
 func placeBlock() {
-	stepX := -fmath.Sin(-player.yaw) * fmath.Cos(player.pitch) * 0.2
-	stepY := -fmath.Sin(player.pitch) * 0.2
- 	stepZ := -fmath.Cos(-player.yaw) * fmath.Cos(player.pitch) * 0.2
-	bX := player.pos[0]
-	bY := player.pos[1] + EYE_HEIGHT
-	bZ := player.pos[2]
-	for stepCount := 100; stepCount > 0; stepCount-- {
-		if w.GetBlock(int(bX), int(bY), int(bZ)) != nil {
-			bX -= stepX
-			bY -= stepY
-			bZ -= stepZ
-			w.SetBlock(int(bX), int(bY), int(bZ), br.ByName("gold_block"))
-			return
-		} else {
-			bX += stepX
-			bY += stepY
-			bZ += stepZ
-		}
+	if pos, exists := player.GetPlaceCoords(&w); exists {
+		w.SetBlock(pos.X, pos.Y, pos.Z, br.ByName(placeable[selected]))
 	}
 }
 
-func onKey(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
-	if key == glfw.KeyW {
-		if action == glfw.Release {
-			movementX = 0.0
-		} else {
-			movementX = 0.12
-		}
-	}
-	if key == glfw.KeyS {
-		if action == glfw.Release {
-			movementX = 0.0
-		} else {
-			movementX = -0.12
+// MAX_TICKS_PER_UPDATE bounds the ticks caught up on in one update, so that
+// a long stall doesn't stall the next frame too.
+const MAX_TICKS_PER_UPDATE = 10
+
+// UNLOAD_TIME is how often the regions far from the player are unloaded.
+const UNLOAD_TIME = 5 * time.Second
+
+// tickTime is the time passed since the world was last ticked.
+var tickTime time.Duration
+
+// unloadTime is the time passed since regions were last unloaded.
+var unloadTime time.Duration
+
+// update advances the world and the player by nanoTime. The world is
+// ticked every TICK_TIME, and unloaded beyond the view distance every
+// UNLOAD_TIME.
+func update(nanoTime time.Duration, viewDistance int) {
+	tickTime += nanoTime
+	for i := 0; tickTime >= TICK_TIME; i++ {
+		if i == MAX_TICKS_PER_UPDATE {
+			tickTime = 0
+			break
 		}
-	}
-	if key == glfw.KeyA {
-		if action == glfw.Release {
-			movementZ = 0.0
-		} else {
-			movementZ = -0.12
+		scheduler.Tick()
+		tickTime -= TICK_TIME
+	}
+	pos := player.Pos.BlockPos()
+	w.LoadAround(pos.X, pos.Y, pos.Z, viewDistance, 16)
+	unloadTime += nanoTime
+	if unloadTime >= UNLOAD_TIME {
+		unloadTime = 0
+		// decorations spill into the chunks next to those loaded, and the
+		// renderer keeps the chunks just past the view distance
+		if _, err := w.UnloadFar(pos.X, pos.Y, pos.Z, viewDistance+2); err != nil {
+			log.Println("failed to unload chunks:", err)
 		}
 	}
-	if key == glfw.KeyD {
-		if action == glfw.Release {
-			movementZ = 0.0
-		} else {
-			movementZ = 0.12
-		}
-	}
-	if key == glfw.KeySpace {
-		if action == glfw.Press {
-			player.gravity = 0.3
-		}
+	if !w.IsLoaded(pos.X, pos.Y, pos.Z) {
+		return
 	}
+	player.Move(&w, nanoTime, movementX, movementZ)
 }
 
 func init() {
@@ -178,49 +126,65 @@ func init() {
 }
 
 var (
-	w WorldFlat
-	br BlockRegistry
+	w         world.WorldChunked
+	br        world.BlockRegistry
+	scheduler *world.Scheduler
 )
 
+func registerBlocks(br *world.BlockRegistry) {
+	dirt := world.NewBlockSimple("dirt", [6]string{"dirt.png", "dirt.png", "dirt.png", "dirt.png", "dirt.png", "dirt.png"})
+	br.Register(world.NewBlockGrass("grass", [6]string{"dirt.png", "grass.png", "grass_side.png", "grass_side.png", "grass_side.png", "grass_side.png"}, dirt))
+	br.Register(dirt)
+	br.Register(world.NewBlockSimple("stone", [6]string{"stone.png", "stone.png", "stone.png", "stone.png", "stone.png", "stone.png"}))
+	br.Register(world.NewBlockSimple("gold_block", [6]string{"gold_block.png", "gold_block.png", "gold_block.png", "gold_block.png", "gold_block.png", "gold_block.png"}))
+	br.Register(world.NewBlockSlab("stone_slab", [6]string{"stone.png", "stone.png", "stone.png", "stone.png", "stone.png", "stone.png"}))
+	br.Register(world.NewBlockFalling("sand", [6]string{"sand.png", "sand.png", "sand.png", "sand.png", "sand.png", "sand.png"}))
+	br.Register(world.NewBlockSimple("sandstone", [6]string{"sandstone.png", "sandstone.png", "sandstone.png", "sandstone.png", "sandstone.png", "sandstone.png"}))
+	br.Register(world.NewBlockSimple("snow", [6]string{"snow.png", "snow.png", "snow.png", "snow.png", "snow.png", "snow.png"}))
+	br.Register(world.NewBlockSimple("coal_ore", [6]string{"coal_ore.png", "coal_ore.png", "coal_ore.png", "coal_ore.png", "coal_ore.png", "coal_ore.png"}))
+	br.Register(world.NewBlockSimple("iron_ore", [6]string{"iron_ore.png", "iron_ore.png", "iron_ore.png", "iron_ore.png", "iron_ore.png", "iron_ore.png"}))
+	br.Register(world.NewBlockSimple("gold_ore", [6]string{"gold_ore.png", "gold_ore.png", "gold_ore.png", "gold_ore.png", "gold_ore.png", "gold_ore.png"}))
+	br.Register(world.NewBlockLog("log", "log_top.png", "log_side.png"))
+	br.Register(world.NewBlockLayered("leaves", [6]string{"leaves.png", "leaves.png", "leaves.png", "leaves.png", "leaves.png", "leaves.png"}, world.LAYER_CUTOUT))
+	br.Register(world.NewBlockLayered("glass", [6]string{"glass.png", "glass.png", "glass.png", "glass.png", "glass.png", "glass.png"}, world.LAYER_TRANSLUCENT))
+	br.Register(world.NewBlockFluid("water", "water.png", world.LAYER_TRANSLUCENT, 0, 7, 5))
+	br.Register(world.NewBlockFluid("lava", "lava.png", world.LAYER_OPAQUE, world.MAX_LIGHT, 3, 30))
+	br.Register(world.NewBlockLight("lamp", [6]string{"lamp.png", "lamp.png", "lamp.png", "lamp.png", "lamp.png", "lamp.png"}, world.MAX_LIGHT))
+}
+
 func main() {
 	flag.Parse()
 
 	fps = NewAverage(256)
-	player.pos = Vec3{8, MAP_H + 16, 8}
-
-	br = NewBlockRegistry()
-	br.Register(&BlockSimple{name: "grass", textures: [6]string{"dirt.png", "grass.png", "grass_side.png", "grass_side.png", "grass_side.png", "grass_side.png"}})
-	br.Register(&BlockSimple{name: "dirt", textures: [6]string{"dirt.png", "dirt.png", "dirt.png", "dirt.png", "dirt.png", "dirt.png"}})
-	br.Register(&BlockSimple{name: "stone", textures: [6]string{"stone.png", "stone.png", "stone.png", "stone.png", "stone.png", "stone.png"}})
-	br.Register(&BlockSimple{name: "gold_block", textures: [6]string{"gold_block.png", "gold_block.png", "gold_block.png", "gold_block.png", "gold_block.png", "gold_block.png"}})
+	player.Pos = world.Vec3{8, world.MAP_H + 16, 8}
 
-	w = NewWorldFlat(br)
+	br = world.NewBlockRegistry()
+	registerBlocks(&br)
 
-	fmt.Printf("Loading...\n")
-	if err := glfw.Init(); err != nil {
-		log.Fatalln("failed to initialize glfw:", err)
-	}
-	defer glfw.Terminate()
-
-	glfw.WindowHint(glfw.ContextVersionMajor, 2)
-	glfw.WindowHint(glfw.ContextVersionMinor, 1)
-	window, err := glfw.CreateWindow(800, 600, "GM-M1-142, strona 12", nil, nil)
-	if err != nil {
-		panic(err)
+	if _, err := os.Stat(*worlddir); err == nil {
+		fmt.Printf("Loading world from %s...\n", *worlddir)
+		if w, err = world.LoadWorld(*worlddir, br); err != nil {
+			log.Fatalln("failed to load world:", err)
+		}
+	} else {
+		worldSeed := time.Now().UnixNano()
+		if *seed != "" {
+			worldSeed = world.ParseSeed(*seed)
+		}
+		fmt.Printf("Generating world with seed %d\n", worldSeed)
+		g, err := world.NewGenerator(*generator, worldSeed, *preset, br)
+		if err != nil {
+			log.Fatalln("failed to create generator:", err)
+		}
+		w = world.NewWorldChunked(br, g)
+		if err := w.SetSaveDir(*worlddir); err != nil {
+			log.Fatalln("failed to set save directory:", err)
+		}
 	}
-	window.MakeContextCurrent()
-	window.SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
-	window.SetKeyCallback(onKey)
-	window.SetCursorPosCallback(onMove)
-	window.SetFramebufferSizeCallback(onResize)
-	window.SetMouseButtonCallback(onMouse)
-
-	//glfw.SwapInterval(0)
-
-	render.Init(800, 600, *debugtextures)
-	defer render.Deinit()
-
-	w.RegisterRenderListener(&render)
+	scheduler = world.NewScheduler(&w, time.Now().UnixNano())
+	w.RegisterRenderListener(world.NewFluids(scheduler))
+	pos := player.Pos.BlockPos()
+	w.LoadAround(pos.X, pos.Y, pos.Z, 2, 1000)
 
 	if *cpuprofile {
 		fmt.Printf("CPU profiling ON!")
@@ -232,48 +196,20 @@ func main() {
         	defer pprof.StopCPUProfile()
 	}
 
-	for !window.ShouldClose() {
-		t := time.Now()
-		render.Render(&player, &w)
-		window.SwapBuffers()
-		glfw.PollEvents()
-		nanoTime := time.Since(t)
-		movementLX := movementX * float32(nanoTime) / (16 * 1000000)
-		movementLZ := movementZ * float32(nanoTime) / (16 * 1000000)
-		gravityLD := 0.5 * float32(nanoTime) / (1000 * 1000000)
-		fpsNow := float64(1000000000) / float64(nanoTime)
-		fps.Push(fpsNow)
-		//fmt.Printf("%.2f (%.2f) [%.2f %.2f %.2f]\n", fps.Get(), nanoTime, player.pos[0], player.pos[1], player.pos[2])
-
-		// player.pos[1] - player.pitch * movementLD,
-		// fmath.Cos(player.pitch)
-
-		// fall
-		if w.GetBlock(int(player.pos[0]), int(fmath.Ceil(player.pos[1])) - 1, int(player.pos[2])) == nil {
-			player.gravity -= gravityLD
-		} else if player.gravity < 0 {
-			player.gravity = 0
-		}
-
-		if player.gravity < 0 {
-			bY := player.pos[1]
-			for w.GetBlock(int(player.pos[0]), int(fmath.Ceil(player.pos[1])), int(player.pos[2])) == nil && player.pos[1] > (bY + player.gravity) {
-				player.pos[1] -= 0.0025
-			}
-		} else if player.gravity > 0 {
-			bY := player.pos[1]
-			for w.GetBlock(int(player.pos[0]), int(fmath.Ceil(player.pos[1])), int(player.pos[2])) == nil && player.pos[1] < (bY + player.gravity) {
-				player.pos[1] += 0.0025
-			}
-		}
+	if *benchmesh {
+		benchmarkMesh(2)
+		return
+	}
 
-		nx := player.pos[0] - fmath.Sin(-player.yaw) * movementLX + fmath.Cos(-player.yaw) * movementLZ
-		nz := player.pos[2] - fmath.Cos(-player.yaw) * movementLX - fmath.Sin(-player.yaw) * movementLZ
+	if *headless {
+		runHeadless(*ticks)
+	} else if err := runClient(); err != nil {
+		log.Fatalln(err)
+	}
 
-		if w.GetBlock(int(nx), int(fmath.Ceil(player.pos[1])), int(nz)) == nil {
-			player.pos[0] = nx
-			player.pos[2] = nz
-		}
+	fmt.Printf("Saving world to %s...\n", *worlddir)
+	if err := world.SaveWorld(&w, *worlddir); err != nil {
+		log.Println("failed to save world:", err)
 	}
 
 	if *heapprofile {
@@ -3,8 +3,8 @@ module github.com/asiekierka/reimagined-disco
 go 1.19
 
 require (
-	github.com/barnex/fmath v0.0.0-20150108074215-ec9671f295c2 // indirect
-	github.com/go-gl/gl v0.0.0-20211210172815-726fda9656d6 // indirect
-	github.com/go-gl/glfw v0.0.0-20220806181222-55e207c401ad // indirect
-	github.com/larspensjo/Go-simplex-noise v0.0.0-20121005164837-bfdcb9fc4b93 // indirect
+	github.com/barnex/fmath v0.0.0-20150108074215-ec9671f295c2
+	github.com/go-gl/gl v0.0.0-20211210172815-726fda9656d6
+	github.com/go-gl/glfw v0.0.0-20220806181222-55e207c401ad
+	github.com/larspensjo/Go-simplex-noise v0.0.0-20121005164837-bfdcb9fc4b93
 )
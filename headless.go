@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+const (
+	TICK_TIME = 50 * time.Millisecond
+	// HEADLESS_LOAD_DISTANCE is the radius, in chunks, kept loaded around
+	// the player when running without a window.
+	HEADLESS_LOAD_DISTANCE = 4
+)
+
+// runHeadless simulates the world at a fixed tick rate, without touching
+// GLFW or OpenGL, until interrupted or until maxTicks ticks have passed.
+func runHeadless(maxTicks int) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(TICK_TIME)
+	defer ticker.Stop()
+
+	fmt.Printf("Running headless...\n")
+	for tick := 0; maxTicks == 0 || tick < maxTicks; tick++ {
+		select {
+		case <-interrupt:
+			return
+		case <-ticker.C:
+		}
+		update(TICK_TIME, HEADLESS_LOAD_DISTANCE)
+		if tick%100 == 0 {
+			fmt.Printf("tick %d [%.2f %.2f %.2f]\n", tick, player.Pos[0], player.Pos[1], player.Pos[2])
+		}
+	}
+}
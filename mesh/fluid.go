@@ -0,0 +1,71 @@
+package mesh
+
+import (
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// fluidCorner returns the height of the surface of fluid f at the corner
+// x, z of position y: the average height of the fluid in the four columns
+// around the corner, or the top of the position if any of them has the
+// fluid above it.
+func (mr *mesher) fluidCorner(f *world.BlockFluid, x int, y int, z int) float32 {
+	sum, n := float32(0), 0
+	for dz := -1; dz <= 0; dz++ {
+		for dx := -1; dx <= 0; dx++ {
+			b, ok := mr.w.GetBlock(x+dx, y, z+dz).(*world.BlockFluid)
+			if !ok || b.Name() != f.Name() {
+				continue
+			}
+			if above := mr.w.GetBlock(x+dx, y+1, z+dz); above != nil && above.Name() == f.Name() {
+				return 1
+			}
+			sum += b.Height()
+			n++
+		}
+	}
+	return sum / float32(n)
+}
+
+// fluidFaceHidden reports whether the d side of the fluid f at x, y, z is
+// hidden. Fluids don't show the sides they share with the same fluid; the
+// surface is only hidden by more of the fluid above it.
+func (mr *mesher) fluidFaceHidden(f *world.BlockFluid, x int, y int, z int, d world.Direction) bool {
+	off := d.Offset()
+	n := mr.w.GetBlock(x+off.X, y+off.Y, z+off.Z)
+	if n != nil && n.Name() == f.Name() {
+		return true
+	}
+	return d != world.UP && mr.faceHidden(f, x, y, z, d)
+}
+
+// renderFluid appends the faces of the fluid f at x, y, z, its surface
+// sloping between the heights of the fluid around it.
+func (mr *mesher) renderFluid(x int, y int, z int, f *world.BlockFluid) {
+	var heights [2][2]float32
+	for cz := 0; cz < 2; cz++ {
+		for cx := 0; cx < 2; cx++ {
+			heights[cx][cz] = mr.fluidCorner(f, x+cx, y, z+cz)
+		}
+	}
+	model := f.GetModel(mr.atlas)
+	for i := 0; i < 6; i++ {
+		d := world.Direction(i)
+		if mr.fluidFaceHidden(f, x, y, z, d) {
+			continue
+		}
+		quads := model.FaceQuads[i]
+		if d == world.UP {
+			// a lowered surface is not on the side of the block
+			quads = append(quads[:len(quads):len(quads)], model.Quads...)
+		}
+		for _, quad := range quads {
+			for v := range quad.V {
+				c := &quad.V[v].Coord
+				if c[1] > 0 {
+					c[1] = heights[int(c[0])][int(c[2])]
+				}
+			}
+			mr.renderQuad(x, y, z, &quad, d)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package mesh
+
+import (
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// greedyFace is a visible full face queued for merging. Faces only merge
+// with faces lit the same way at every vertex, which keeps the light
+// interpolated across a merged quad the same as across its faces.
+type greedyFace struct {
+	quad  *world.Quad
+	light [4]float32
+	flip  bool
+	layer world.RenderLayer
+}
+
+func (f greedyFace) mergesWith(o greedyFace) bool {
+	if f.quad == nil || o.quad == nil || f.light != o.light || f.flip != o.flip || f.layer != o.layer {
+		return false
+	}
+	return f.quad == o.quad || *f.quad == *o.quad
+}
+
+// fullFace returns the quad covering the whole d side of the unit cube in
+// model, if the side consists of exactly that quad.
+func fullFace(model *world.Model, d int) *world.Quad {
+	if len(model.FaceQuads[d]) != 1 {
+		return nil
+	}
+	q := &model.FaceQuads[d][0]
+	axes := faceAxes[d]
+	plane := float32(d & 1)
+	var seen [2][2]bool
+	for i := 0; i < 4; i++ {
+		c := q.V[i].Coord
+		if c[axes[0]] != plane {
+			return nil
+		}
+		for j := 0; j < 2; j++ {
+			switch c[axes[j+1]] {
+			case 0:
+				seen[j][0] = true
+			case 1:
+				seen[j][1] = true
+			default:
+				return nil
+			}
+		}
+	}
+	if !seen[0][0] || !seen[0][1] || !seen[1][0] || !seen[1][1] {
+		return nil
+	}
+	return q
+}
+
+// BuildChunkGreedy is like BuildChunk, but merges coplanar neighboring full
+// cube faces with the same texture and light into larger quads. The mesh
+// is tiled, so the texture repeats across merged quads instead of being
+// stretched.
+func BuildChunkGreedy(w world.BlockAccess, atlas world.TextureAtlas, p world.Position, m *Mesh) {
+	build(w, atlas, p, [world.RENDER_LAYERS]*Mesh{m, m, m}, Options{Greedy: true})
+}
+
+func (mr *mesher) buildGreedy(p world.Position) {
+	for _, m := range mr.layers {
+		m.Tiled = true
+	}
+	faces := make([]greedyFace, 6*world.CHUNK_VOLUME)
+
+	for y := 0; y < 16; y++ {
+		py := p.Y<<4 + y
+		for z := 0; z < 16; z++ {
+			pz := p.Z<<4 + z
+			for x := 0; x < 16; x++ {
+				px := p.X<<4 + x
+				block := mr.w.GetBlock(px, py, pz)
+				if block == nil {
+					continue
+				}
+				layer := block.RenderLayer()
+				mr.m = mr.layers[layer]
+				if fluid, ok := block.(*world.BlockFluid); ok {
+					mr.renderFluid(px, py, pz, fluid)
+					continue
+				}
+				model := block.GetModel(mr.atlas)
+				local := [3]int{x, y, z}
+				for d := 0; d < 6; d++ {
+					if mr.faceHidden(block, px, py, pz, world.Direction(d)) {
+						continue
+					}
+					if q := fullFace(&model, d); q != nil {
+						axes := faceAxes[d]
+						i := ((d*16+local[axes[0]])*16+local[axes[2]])*16 + local[axes[1]]
+						light, flip := mr.faceLight(px, py, pz, q, world.Direction(d))
+						faces[i] = greedyFace{q, light, flip, layer}
+						continue
+					}
+					for _, quad := range model.FaceQuads[d] {
+						mr.renderQuad(px, py, pz, &quad, world.Direction(d))
+					}
+				}
+				for _, quad := range model.Quads {
+					mr.renderQuad(px, py, pz, &quad, world.UNKNOWN)
+				}
+			}
+		}
+	}
+
+	origin := [3]int{p.X << 4, p.Y << 4, p.Z << 4}
+	for d := 0; d < 6; d++ {
+		for s := 0; s < 16; s++ {
+			slice := faces[(d*16+s)*256 : (d*16+s+1)*256]
+			for v := 0; v < 16; v++ {
+				for u := 0; u < 16; u++ {
+					f := slice[v*16+u]
+					if f.quad == nil {
+						continue
+					}
+					width := 1
+					for u+width < 16 && slice[v*16+u+width].mergesWith(f) {
+						width++
+					}
+					height := 1
+				grow:
+					for v+height < 16 {
+						for k := 0; k < width; k++ {
+							if !slice[(v+height)*16+u+k].mergesWith(f) {
+								break grow
+							}
+						}
+						height++
+					}
+					for j := 0; j < height; j++ {
+						for k := 0; k < width; k++ {
+							slice[(v+j)*16+u+k] = greedyFace{}
+						}
+					}
+					mr.layers[f.layer].appendMerged(f, faceAxes[d], origin, s, u, v, width, height)
+				}
+			}
+		}
+	}
+}
+
+// appendMerged appends the face f stretched over width by height blocks,
+// starting at block (u, v) of slice s.
+func (m *Mesh) appendMerged(f greedyFace, axes [3]int, origin [3]int, s int, u int, v int, width int, height int) {
+	q := f.quad
+	minT, maxT := texcoordRange(q)
+	size := [2]float32{float32(width), float32(height)}
+
+	// find which of the face's axes each texture coordinate follows
+	var texAxis [2]int
+	for j := 0; j < 2; j++ {
+		texAxis[j] = 1
+		same, flipped := true, true
+		for i := 0; i < 4; i++ {
+			l := localTexcoord(q.V[i].Texcoord, minT, maxT)[j]
+			c := q.V[i].Coord[axes[1]]
+			same = same && l == c
+			flipped = flipped && l == 1-c
+		}
+		if same || flipped {
+			texAxis[j] = 0
+		}
+	}
+
+	var base world.Vec3
+	base[axes[0]] = float32(origin[axes[0]] + s)
+	base[axes[1]] = float32(origin[axes[1]] + u)
+	base[axes[2]] = float32(origin[axes[2]] + v)
+	for k := 0; k < 4; k++ {
+		i := k
+		if f.flip {
+			i = (k + 1) & 3
+		}
+		c := q.V[i].Coord
+		var coord world.Vec3
+		coord[axes[0]] = base[axes[0]] + c[axes[0]]
+		coord[axes[1]] = base[axes[1]] + c[axes[1]]*size[0]
+		coord[axes[2]] = base[axes[2]] + c[axes[2]]*size[1]
+		l := localTexcoord(q.V[i].Texcoord, minT, maxT)
+		l[0] *= size[texAxis[0]]
+		l[1] *= size[texAxis[1]]
+		m.appendTiledVertex(coord, q.Normal, q.V[i].Color.Scale(f.light[i]), l, minT)
+	}
+	m.Count += 4
+}
@@ -0,0 +1,179 @@
+package mesh
+
+import (
+	"sort"
+
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// VERTEX_SIZE is the number of floats per vertex: position, normal, color
+// and texture coordinates.
+const VERTEX_SIZE = 11
+
+// TILED_VERTEX_SIZE is the number of floats per vertex of tiled meshes,
+// whose texture coordinates are counted in texture tiles and followed by
+// the origin of the tile in the texture sheet. This lets a single quad
+// repeat its texture over several blocks.
+const TILED_VERTEX_SIZE = 13
+
+// Mesh is a list of quads, stored as interleaved vertices ready to be
+// uploaded to a vertex buffer.
+type Mesh struct {
+	Data  []float32
+	Count int32
+	Tiled bool
+}
+
+func (v *Mesh) Append(q world.Quad) {
+	for i := 0; i < 4; i++ {
+		v.Data = append(v.Data, q.V[i].Coord[0], q.V[i].Coord[1], q.V[i].Coord[2],
+			q.Normal[0], q.Normal[1], q.Normal[2],
+			q.V[i].Color[0], q.V[i].Color[1], q.V[i].Color[2],
+			q.V[i].Texcoord[0], q.V[i].Texcoord[1])
+	}
+	v.Count += 4
+}
+
+func (v *Mesh) AppendFancy(q *world.Quad, coordOffset world.Vec3, texCoordOffset world.Vec2, lightLevel float32) {
+	for i := 0; i < 4; i++ {
+		v.Data = append(v.Data, q.V[i].Coord[0]+coordOffset[0], q.V[i].Coord[1]+coordOffset[1], q.V[i].Coord[2]+coordOffset[2],
+			q.Normal[0], q.Normal[1], q.Normal[2],
+			q.V[i].Color[0]*lightLevel, q.V[i].Color[1]*lightLevel, q.V[i].Color[2]*lightLevel,
+			q.V[i].Texcoord[0]+texCoordOffset[0], q.V[i].Texcoord[1]+texCoordOffset[1])
+	}
+	v.Count += 4
+}
+
+// AppendShaded appends q, multiplying the color of each of its vertices by
+// the matching light level. If flip is set, the vertices are rotated by one
+// so that the quad is split into triangles along its other diagonal. Tiled
+// meshes treat the range of the quad's texture coordinates as one tile.
+func (v *Mesh) AppendShaded(q *world.Quad, coordOffset world.Vec3, light [4]float32, flip bool) {
+	var minT, maxT world.Vec2
+	if v.Tiled {
+		minT, maxT = texcoordRange(q)
+	}
+	for k := 0; k < 4; k++ {
+		i := k
+		if flip {
+			i = (k + 1) & 3
+		}
+		coord := q.V[i].Coord.Translate(coordOffset)
+		color := q.V[i].Color.Scale(light[i])
+		if v.Tiled {
+			v.appendTiledVertex(coord, q.Normal, color, localTexcoord(q.V[i].Texcoord, minT, maxT), minT)
+		} else {
+			v.Data = append(v.Data, coord[0], coord[1], coord[2],
+				q.Normal[0], q.Normal[1], q.Normal[2],
+				color[0], color[1], color[2],
+				q.V[i].Texcoord[0], q.V[i].Texcoord[1])
+		}
+	}
+	v.Count += 4
+}
+
+func (v *Mesh) appendTiledVertex(coord world.Vec3, normal world.Vec3, color world.Vec3, local world.Vec2, origin world.Vec2) {
+	v.Data = append(v.Data, coord[0], coord[1], coord[2],
+		normal[0], normal[1], normal[2],
+		color[0], color[1], color[2],
+		local[0], local[1],
+		origin[0], origin[1])
+}
+
+func texcoordRange(q *world.Quad) (world.Vec2, world.Vec2) {
+	minT := q.V[0].Texcoord
+	maxT := q.V[0].Texcoord
+	for i := 1; i < 4; i++ {
+		for j := 0; j < 2; j++ {
+			if q.V[i].Texcoord[j] < minT[j] {
+				minT[j] = q.V[i].Texcoord[j]
+			}
+			if q.V[i].Texcoord[j] > maxT[j] {
+				maxT[j] = q.V[i].Texcoord[j]
+			}
+		}
+	}
+	return minT, maxT
+}
+
+// localTexcoord maps a texture coordinate inside [minT, maxT] to [0, 1].
+func localTexcoord(t world.Vec2, minT world.Vec2, maxT world.Vec2) world.Vec2 {
+	var l world.Vec2
+	for j := 0; j < 2; j++ {
+		if maxT[j] > minT[j] {
+			l[j] = (t[j] - minT[j]) / (maxT[j] - minT[j])
+		}
+	}
+	return l
+}
+
+func (v *Mesh) Reset() {
+	v.Data = nil
+	v.Count = 0
+	v.Tiled = false
+}
+
+// Quads returns the number of quads in the mesh.
+func (v *Mesh) Quads() int {
+	return int(v.Count / 4)
+}
+
+// VertexSize returns the number of floats per vertex of the mesh.
+func (v *Mesh) VertexSize() int {
+	if v.Tiled {
+		return TILED_VERTEX_SIZE
+	}
+	return VERTEX_SIZE
+}
+
+// Vertex returns the position of the i-th vertex of the mesh.
+func (v *Mesh) Vertex(i int) world.Vec3 {
+	o := i * v.VertexSize()
+	return world.Vec3{v.Data[o], v.Data[o+1], v.Data[o+2]}
+}
+
+// SortQuads orders the quads of the mesh from the one farthest from eye to
+// the nearest, so that translucent quads blend correctly when drawn in
+// order.
+func (v *Mesh) SortQuads(eye world.Vec3) {
+	stride := 4 * v.VertexSize()
+	n := v.Quads()
+	order := make([]int, n)
+	dist := make([]float32, n)
+	for q := 0; q < n; q++ {
+		order[q] = q
+		var center world.Vec3
+		for i := 0; i < 4; i++ {
+			center = center.Translate(v.Vertex(q*4 + i))
+		}
+		d := center.Scale(0.25).Translate(eye.Scale(-1))
+		dist[q] = d[0]*d[0] + d[1]*d[1] + d[2]*d[2]
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return dist[order[i]] > dist[order[j]]
+	})
+	data := make([]float32, len(v.Data))
+	for i, q := range order {
+		copy(data[i*stride:(i+1)*stride], v.Data[q*stride:(q+1)*stride])
+	}
+	v.Data = data
+}
+
+// Layers holds a mesh for each render layer of a chunk, indexed by
+// world.RenderLayer.
+type Layers [world.RENDER_LAYERS]Mesh
+
+func (l *Layers) Reset() {
+	for i := range l {
+		l[i].Reset()
+	}
+}
+
+// Quads returns the number of quads in all layers.
+func (l *Layers) Quads() int {
+	n := 0
+	for i := range l {
+		n += l[i].Quads()
+	}
+	return n
+}
@@ -0,0 +1,227 @@
+package mesh
+
+import (
+	"math"
+
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// Options selects how chunks are meshed.
+type Options struct {
+	// Greedy merges neighboring full cube faces into larger quads, making
+	// a tiled mesh.
+	Greedy bool
+	// Smooth blends the light of the blocks around each vertex and darkens
+	// corners hidden by neighboring blocks, instead of lighting each face
+	// evenly.
+	Smooth bool
+}
+
+func isSolidSide(w world.BlockAccess, x int, y int, z int, side world.Direction) bool {
+	return world.IsSideSolid(w.GetBlock(x, y, z), side)
+}
+
+var lightLevelScaler = []float32{0.55, 1.0, 0.85, 0.85, 0.7, 0.7, 1.0}
+
+// lightBrightness maps light levels to color multipliers.
+var lightBrightness [world.MAX_LIGHT + 1]float32
+
+// occlusionScaler maps the number of unobstructed blocks around a vertex
+// to a color multiplier.
+var occlusionScaler = [4]float32{0.45, 0.6, 0.8, 1.0}
+
+func init() {
+	for l := range lightBrightness {
+		lightBrightness[l] = float32(math.Pow(0.8, float64(world.MAX_LIGHT-l)))
+	}
+}
+
+// faceAxes lists, for each direction, the axis the face's normal runs
+// along followed by the two axes spanning the face.
+var faceAxes = [6][3]int{
+	{1, 0, 2}, {1, 0, 2},
+	{0, 1, 2}, {0, 1, 2},
+	{2, 0, 1}, {2, 0, 1},
+}
+
+// mesher builds the mesh of a single chunk, appending the quads of each
+// block to the mesh of its render layer, m.
+type mesher struct {
+	w      world.BlockAccess
+	la     world.LightAccess
+	atlas  world.TextureAtlas
+	layers [world.RENDER_LAYERS]*Mesh
+	m      *Mesh
+	opts   Options
+}
+
+func newMesher(w world.BlockAccess, atlas world.TextureAtlas, layers [world.RENDER_LAYERS]*Mesh, opts Options) *mesher {
+	la, _ := w.(world.LightAccess)
+	return &mesher{w: w, la: la, atlas: atlas, layers: layers, opts: opts}
+}
+
+// faceHidden reports whether the d side of block, at x, y, z, is hidden by
+// the block next to it. Translucent blocks also hide the sides they share
+// with blocks of the same type, such as the panes inside a wall of glass.
+func (mr *mesher) faceHidden(block world.Block, x int, y int, z int, d world.Direction) bool {
+	off := d.Offset()
+	n := mr.w.GetBlock(x+off.X, y+off.Y, z+off.Z)
+	if n == nil {
+		return false
+	}
+	if n.IsSideSolid(d ^ 1) {
+		return true
+	}
+	return block.RenderLayer() == world.LAYER_TRANSLUCENT && n.Name() == block.Name()
+}
+
+// brightness returns the color multiplier of the light at x, y, z. Worlds
+// without light are fully lit.
+func (mr *mesher) brightness(x int, y int, z int) float32 {
+	if mr.la == nil {
+		return 1
+	}
+	sky, block := mr.la.GetLight(x, y, z)
+	if block > sky {
+		sky = block
+	}
+	return lightBrightness[sky]
+}
+
+// faceLight returns the color multipliers of the vertices of quad q on the
+// side d of the block at x, y, z, and whether q should be split along its
+// other diagonal. Quads not on a side are lit by the block itself.
+func (mr *mesher) faceLight(x int, y int, z int, q *world.Quad, d world.Direction) ([4]float32, bool) {
+	off := d.Offset()
+	fx, fy, fz := x+off.X, y+off.Y, z+off.Z
+	if !mr.opts.Smooth || d == world.UNKNOWN {
+		l := mr.brightness(fx, fy, fz) * lightLevelScaler[int(d)]
+		return [4]float32{l, l, l, l}, false
+	}
+
+	var light [4]float32
+	axes := faceAxes[d]
+	for i := 0; i < 4; i++ {
+		// step towards the vertex along both axes spanning the face
+		var s1, s2 [3]int
+		s1[axes[1]] = -1
+		if q.V[i].Coord[axes[1]] >= 0.5 {
+			s1[axes[1]] = 1
+		}
+		s2[axes[2]] = -1
+		if q.V[i].Coord[axes[2]] >= 0.5 {
+			s2[axes[2]] = 1
+		}
+		light[i] = mr.vertexLight(fx, fy, fz, s1, s2, d) * lightLevelScaler[int(d)]
+	}
+	return light, light[1]+light[3] > light[0]+light[2]
+}
+
+// vertexLight returns the light at the vertex between the position fx, fy,
+// fz in front of a face, its two neighbors s1 and s2 along the face and the
+// position diagonal to it, darkened by those which occlude the vertex.
+func (mr *mesher) vertexLight(fx int, fy int, fz int, s1 [3]int, s2 [3]int, d world.Direction) float32 {
+	side := d ^ 1
+	o1 := isSolidSide(mr.w, fx+s1[0], fy+s1[1], fz+s1[2], side)
+	o2 := isSolidSide(mr.w, fx+s2[0], fy+s2[1], fz+s2[2], side)
+	oc := isSolidSide(mr.w, fx+s1[0]+s2[0], fy+s1[1]+s2[1], fz+s1[2]+s2[2], side)
+
+	sum, count := mr.brightness(fx, fy, fz), float32(1)
+	if !o1 {
+		sum += mr.brightness(fx+s1[0], fy+s1[1], fz+s1[2])
+		count++
+	}
+	if !o2 {
+		sum += mr.brightness(fx+s2[0], fy+s2[1], fz+s2[2])
+		count++
+	}
+	if !oc && !(o1 && o2) {
+		sum += mr.brightness(fx+s1[0]+s2[0], fy+s1[1]+s2[1], fz+s1[2]+s2[2])
+		count++
+	}
+	return sum / count * occlusionScaler[vertexOcclusion(o1, o2, oc)]
+}
+
+// vertexOcclusion returns how many of the three blocks around a vertex
+// leave it unobstructed. A vertex between two occluding sides is fully
+// hidden, whatever is in the corner.
+func vertexOcclusion(side1 bool, side2 bool, corner bool) int {
+	if side1 && side2 {
+		return 0
+	}
+	n := 3
+	for _, o := range [3]bool{side1, side2, corner} {
+		if o {
+			n--
+		}
+	}
+	return n
+}
+
+func (mr *mesher) renderQuad(x int, y int, z int, q *world.Quad, d world.Direction) {
+	light, flip := mr.faceLight(x, y, z, q, d)
+	mr.m.AppendShaded(q, world.Vec3{float32(x), float32(y), float32(z)}, light, flip)
+}
+
+func (mr *mesher) renderModel(x int, y int, z int, block world.Block, model world.Model) {
+	for i := 0; i < 6; i++ {
+		if !mr.faceHidden(block, x, y, z, world.Direction(i)) {
+			for _, quad := range model.FaceQuads[i] {
+				mr.renderQuad(x, y, z, &quad, world.Direction(i))
+			}
+		}
+	}
+	for _, quad := range model.Quads {
+		mr.renderQuad(x, y, z, &quad, world.UNKNOWN)
+	}
+}
+
+// Build appends the mesh of the 16x16x16 chunk at chunk position p to the
+// meshes of its render layers in m, meshed as selected by opts.
+func Build(w world.BlockAccess, atlas world.TextureAtlas, p world.Position, m *Layers, opts Options) {
+	var layers [world.RENDER_LAYERS]*Mesh
+	for i := range layers {
+		layers[i] = &m[i]
+	}
+	build(w, atlas, p, layers, opts)
+}
+
+// build appends the mesh of the chunk at p to layers.
+func build(w world.BlockAccess, atlas world.TextureAtlas, p world.Position, layers [world.RENDER_LAYERS]*Mesh, opts Options) {
+	mr := newMesher(w, atlas, layers, opts)
+	if opts.Greedy {
+		mr.buildGreedy(p)
+	} else {
+		mr.build(p)
+	}
+}
+
+// BuildChunk appends the mesh of the 16x16x16 chunk at chunk position p to
+// m. Faces hidden by a solid side of a neighboring block, including blocks
+// in neighboring chunks, are left out. Faces are shaded by the light in
+// front of them if w is a LightAccess. All render layers share m.
+func BuildChunk(w world.BlockAccess, atlas world.TextureAtlas, p world.Position, m *Mesh) {
+	build(w, atlas, p, [world.RENDER_LAYERS]*Mesh{m, m, m}, Options{})
+}
+
+func (mr *mesher) build(p world.Position) {
+	for y := 0; y < 16; y++ {
+		py := p.Y<<4 + y
+		for z := 0; z < 16; z++ {
+			pz := p.Z<<4 + z
+			for x := 0; x < 16; x++ {
+				px := p.X<<4 + x
+				block := mr.w.GetBlock(px, py, pz)
+				if block == nil {
+					continue
+				}
+				mr.m = mr.layers[block.RenderLayer()]
+				if fluid, ok := block.(*world.BlockFluid); ok {
+					mr.renderFluid(px, py, pz, fluid)
+				} else {
+					mr.renderModel(px, py, pz, block, block.GetModel(mr.atlas))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package mesh
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// mapWorld is a BlockAccess holding just the blocks set in it.
+type mapWorld map[world.Position]world.Block
+
+func (m mapWorld) GetBlock(x int, y int, z int) world.Block {
+	return m[world.Position{X: x, Y: y, Z: z}]
+}
+
+func (m mapWorld) SetBlock(x int, y int, z int, b world.Block) {
+	m[world.Position{X: x, Y: y, Z: z}] = b
+}
+
+var testStone = world.NewBlockSimple("stone", [6]string{})
+
+func TestBuildChunkQuads(t *testing.T) {
+	cases := []struct {
+		name   string
+		blocks []world.Position
+		chunk  world.Position
+		quads  int
+		greedy int
+	}{
+		{"empty", nil, world.Position{}, 0, 0},
+		{"single cube", []world.Position{{X: 3, Y: 3, Z: 3}}, world.Position{}, 6, 6},
+		{"two adjacent", []world.Position{{X: 3, Y: 3, Z: 3}, {X: 4, Y: 3, Z: 3}}, world.Position{}, 10, 6},
+		{"two apart", []world.Position{{X: 3, Y: 3, Z: 3}, {X: 5, Y: 3, Z: 3}}, world.Position{}, 12, 12},
+		{"on the border", []world.Position{{X: 15, Y: 0, Z: 0}}, world.Position{}, 6, 6},
+		{"outside the chunk", []world.Position{{X: 16, Y: 0, Z: 0}}, world.Position{}, 0, 0},
+		{"across the border", []world.Position{{X: 15, Y: 0, Z: 0}, {X: 16, Y: 0, Z: 0}}, world.Position{}, 5, 5},
+		{"across the border, other side", []world.Position{{X: 15, Y: 0, Z: 0}, {X: 16, Y: 0, Z: 0}}, world.Position{X: 1}, 5, 5},
+		{"negative chunk", []world.Position{{X: -1, Y: -1, Z: -1}, {X: 0, Y: -1, Z: -1}}, world.Position{X: -1, Y: -1, Z: -1}, 5, 5},
+	}
+	for _, c := range cases {
+		w := mapWorld{}
+		for _, p := range c.blocks {
+			w.SetBlock(p.X, p.Y, p.Z, testStone)
+		}
+		var m, g Mesh
+		BuildChunk(w, testAtlas{}, c.chunk, &m)
+		BuildChunkGreedy(w, testAtlas{}, c.chunk, &g)
+		if m.Quads() != c.quads {
+			t.Errorf("%s: %d quads, want %d", c.name, m.Quads(), c.quads)
+		}
+		if g.Quads() != c.greedy {
+			t.Errorf("%s: %d greedy quads, want %d", c.name, g.Quads(), c.greedy)
+		}
+	}
+}
+
+func TestBuildChunkSlab(t *testing.T) {
+	slab := world.NewBlockSlab("slab", [6]string{})
+	w := mapWorld{}
+	w.SetBlock(0, 0, 0, testStone)
+	w.SetBlock(0, 1, 0, slab)
+	var m Mesh
+	BuildChunk(w, testAtlas{}, world.Position{}, &m)
+	// the stone and the slab hide the faces between them
+	if m.Quads() != 10 {
+		t.Errorf("%d quads, want 10", m.Quads())
+	}
+}
+
+func TestVertexOcclusion(t *testing.T) {
+	cases := []struct {
+		side1, side2, corner bool
+		want                 int
+	}{
+		{false, false, false, 3},
+		{false, false, true, 2},
+		{true, false, false, 2},
+		{false, true, false, 2},
+		{true, false, true, 1},
+		{false, true, true, 1},
+		// two sides hide the vertex whatever is in the corner
+		{true, true, false, 0},
+		{true, true, true, 0},
+	}
+	for _, c := range cases {
+		if got := vertexOcclusion(c.side1, c.side2, c.corner); got != c.want {
+			t.Errorf("vertexOcclusion(%v, %v, %v) = %d, want %d", c.side1, c.side2, c.corner, got, c.want)
+		}
+	}
+}
+
+// topLight returns the smooth light of each corner of the top face of the
+// stone at the origin of w, by its x and z, and whether the face is split
+// along its other diagonal.
+func topLight(w world.BlockAccess) (map[[2]float32]float32, [4]float32, bool) {
+	var m Mesh
+	mr := newMesher(w, testAtlas{}, [world.RENDER_LAYERS]*Mesh{&m, &m, &m}, Options{Smooth: true})
+	q := &testStone.GetModel(testAtlas{}).FaceQuads[world.UP][0]
+	light, flip := mr.faceLight(0, 0, 0, q, world.UP)
+	corners := make(map[[2]float32]float32, 4)
+	for i, v := range q.V {
+		corners[[2]float32{v.Coord[0], v.Coord[2]}] = light[i]
+	}
+	return corners, light, flip
+}
+
+func TestSmoothLightCorners(t *testing.T) {
+	up := lightLevelScaler[world.UP]
+	cases := []struct {
+		name     string
+		blocks   []world.Position
+		darkened map[[2]float32]float32
+	}{
+		{"open", nil, nil},
+		{"one side", []world.Position{{X: 1, Y: 1}}, map[[2]float32]float32{{1, 0}: 0.8, {1, 1}: 0.8}},
+		{"one corner", []world.Position{{X: 1, Y: 1, Z: 1}}, map[[2]float32]float32{{1, 1}: 0.8}},
+		{"two sides", []world.Position{{X: 1, Y: 1}, {Y: 1, Z: 1}}, map[[2]float32]float32{{1, 0}: 0.8, {0, 1}: 0.8, {1, 1}: 0.45}},
+		{"side and corner", []world.Position{{X: 1, Y: 1}, {X: 1, Y: 1, Z: 1}}, map[[2]float32]float32{{1, 0}: 0.8, {1, 1}: 0.6}},
+		{"below the face", []world.Position{{X: 1}, {X: 1, Z: 1}}, nil},
+	}
+	for _, c := range cases {
+		w := mapWorld{}
+		w.SetBlock(0, 0, 0, testStone)
+		for _, p := range c.blocks {
+			w.SetBlock(p.X, p.Y, p.Z, testStone)
+		}
+		corners, _, _ := topLight(w)
+		for corner, got := range corners {
+			want := float32(1)
+			if v, ok := c.darkened[corner]; ok {
+				want = v
+			}
+			if d := got - want*up; d > 1e-5 || d < -1e-5 {
+				t.Errorf("%s: corner %v lit %v, want %v", c.name, corner, got, want*up)
+			}
+		}
+	}
+}
+
+// TestSmoothLightFlip checks that a face next to a single occluding corner
+// is split along the diagonal away from the darkened vertex, so that the
+// shadow doesn't bleed across the face.
+func TestSmoothLightFlip(t *testing.T) {
+	for _, corner := range []world.Position{{X: 1, Y: 1, Z: 1}, {X: -1, Y: 1, Z: 1}, {X: 1, Y: 1, Z: -1}, {X: -1, Y: 1, Z: -1}} {
+		w := mapWorld{}
+		w.SetBlock(0, 0, 0, testStone)
+		w.SetBlock(corner.X, corner.Y, corner.Z, testStone)
+		_, light, flip := topLight(w)
+		dark := 0
+		for i := range light {
+			if light[i] < light[dark] {
+				dark = i
+			}
+		}
+		// quads are split along vertices 0 and 2, or 1 and 3 when flipped
+		if wantFlip := dark%2 == 0; flip != wantFlip {
+			t.Errorf("occluder at %v darkens vertex %d, flipped %v", corner, dark, flip)
+		}
+	}
+}
+
+func TestBuildLayers(t *testing.T) {
+	glass := world.NewBlockLayered("glass", [6]string{}, world.LAYER_TRANSLUCENT)
+	ice := world.NewBlockLayered("ice", [6]string{}, world.LAYER_TRANSLUCENT)
+	leaves := world.NewBlockLayered("leaves", [6]string{}, world.LAYER_CUTOUT)
+	w := mapWorld{}
+	// two panes of glass share a hidden face, and the stone next to them
+	// hides the glass face against it but still shows its own
+	w.SetBlock(1, 1, 1, glass)
+	w.SetBlock(2, 1, 1, glass)
+	w.SetBlock(3, 1, 1, testStone)
+	// different translucent blocks show the faces between them
+	w.SetBlock(1, 5, 1, glass)
+	w.SetBlock(2, 5, 1, ice)
+	// as do leaves, which are seen through their holes
+	w.SetBlock(8, 1, 8, leaves)
+	w.SetBlock(9, 1, 8, leaves)
+
+	want := [world.RENDER_LAYERS]int{
+		world.LAYER_OPAQUE:      6,
+		world.LAYER_CUTOUT:      12,
+		world.LAYER_TRANSLUCENT: 9 + 12,
+	}
+	// greedy meshing merges the four long faces of each pair, all of them
+	// having the same texture
+	wantGreedy := [world.RENDER_LAYERS]int{
+		world.LAYER_OPAQUE:      6,
+		world.LAYER_CUTOUT:      8,
+		world.LAYER_TRANSLUCENT: 5 + 8,
+	}
+	for _, opts := range []Options{{}, {Smooth: true}, {Greedy: true}, {Greedy: true, Smooth: true}} {
+		var m Layers
+		Build(w, testAtlas{}, world.Position{}, &m, opts)
+		want := want
+		if opts.Greedy {
+			want = wantGreedy
+		}
+		for layer := range m {
+			if got := m[layer].Quads(); got != want[layer] {
+				t.Errorf("%+v: %d quads in layer %d, want %d", opts, got, layer, want[layer])
+			}
+		}
+	}
+}
+
+// quadDistances returns the squared distance of the center of each quad
+// of m from eye, in order.
+func quadDistances(m *Mesh, eye world.Vec3) []float32 {
+	dist := make([]float32, m.Quads())
+	for q := range dist {
+		var c world.Vec3
+		for i := 0; i < 4; i++ {
+			c = c.Translate(m.Vertex(q*4 + i))
+		}
+		d := c.Scale(0.25).Translate(eye.Scale(-1))
+		dist[q] = d[0]*d[0] + d[1]*d[1] + d[2]*d[2]
+	}
+	return dist
+}
+
+// quadSet returns the quads of m, keyed by their vertex data.
+func quadSet(m *Mesh) map[string]int {
+	set := make(map[string]int)
+	stride := 4 * m.VertexSize()
+	for q := 0; q < m.Quads(); q++ {
+		set[fmt.Sprint(m.Data[q*stride:(q+1)*stride])]++
+	}
+	return set
+}
+
+func TestSortQuads(t *testing.T) {
+	glass := world.NewBlockLayered("glass", [6]string{}, world.LAYER_TRANSLUCENT)
+	w := mapWorld{}
+	for x := 0; x < 8; x += 2 {
+		for z := 0; z < 8; z += 3 {
+			w.SetBlock(x, x/2, z, glass)
+		}
+	}
+	for _, opts := range []Options{{}, {Greedy: true, Smooth: true}} {
+		var m Layers
+		Build(w, testAtlas{}, world.Position{}, &m, opts)
+		tr := &m[world.LAYER_TRANSLUCENT]
+		quads := quadSet(tr)
+		for _, eye := range []world.Vec3{{-5, 1.5, 1.5}, {20, 3, 9}, {4, 2, 4}} {
+			tr.SortQuads(eye)
+			dist := quadDistances(tr, eye)
+			for q := 1; q < len(dist); q++ {
+				if dist[q] > dist[q-1] {
+					t.Errorf("%+v, eye at %v: quad %d is farther than the one before it", opts, eye, q)
+					break
+				}
+			}
+			if !reflect.DeepEqual(quadSet(tr), quads) {
+				t.Errorf("%+v, eye at %v: sorting changed the quads", opts, eye)
+			}
+		}
+	}
+}
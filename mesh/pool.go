@@ -0,0 +1,166 @@
+package mesh
+
+import (
+	"sync"
+
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// Target is what a Pool meshes a chunk for, such as the vertex buffer the
+// meshes are uploaded to. Once a Target is cancelled, the results of its
+// jobs are dropped.
+type Target struct {
+	// cancelled is set under the pool's lock
+	cancelled bool
+}
+
+// poolJob asks for the chunk at pos of w to be meshed for target.
+type poolJob struct {
+	pos    world.Position
+	target *Target
+	w      world.BlockAccess
+	opts   Options
+	seq    uint64
+}
+
+// Result holds the meshes a Pool built for a Target. Seq numbers the job
+// which built them; jobs submitted later have larger numbers.
+type Result struct {
+	Target     *Target
+	Layers     Layers
+	Visibility Visibility
+	Seq        uint64
+}
+
+// Pool meshes chunks on a fixed set of worker goroutines. There is at most
+// one pending job and one finished result per chunk, so neither can
+// outgrow the number of chunks around the player.
+type Pool struct {
+	atlas   world.TextureAtlas
+	lock    sync.Mutex
+	wake    *sync.Cond
+	queue   []world.Position
+	pending map[world.Position]poolJob
+	done    map[world.Position]Result
+	seq     uint64
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// NewPool starts a pool with the given number of workers, meshing with
+// the textures of atlas.
+func NewPool(atlas world.TextureAtlas, workers int) *Pool {
+	p := &Pool{
+		atlas:   atlas,
+		pending: make(map[world.Position]poolJob),
+		done:    make(map[world.Position]Result),
+	}
+	p.wake = sync.NewCond(&p.lock)
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues the chunk at pos of w to be meshed for t without waiting.
+// A job still pending for the same chunk is replaced, keeping its place in
+// the queue.
+func (p *Pool) Submit(pos world.Position, w world.BlockAccess, t *Target, opts Options) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return
+	}
+	p.seq++
+	if _, exists := p.pending[pos]; !exists {
+		p.queue = append(p.queue, pos)
+	}
+	p.pending[pos] = poolJob{pos: pos, target: t, w: w, opts: opts, seq: p.seq}
+	p.wake.Signal()
+}
+
+// Cancel drops the pending job and the finished result of the chunk at
+// pos, whose target t is going away. Jobs for t already being worked on
+// finish, but their results are dropped.
+func (p *Pool) Cancel(pos world.Position, t *Target) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	t.cancelled = true
+	delete(p.pending, pos)
+	delete(p.done, pos)
+}
+
+// Ready reports whether any results are waiting to be taken.
+func (p *Pool) Ready() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.done) > 0
+}
+
+// Take removes and returns the finished result of the chunk at pos.
+func (p *Pool) Take(pos world.Position) (Result, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	res, ok := p.done[pos]
+	if ok {
+		delete(p.done, pos)
+	}
+	return res, ok
+}
+
+// Close stops the workers, dropping the jobs still pending, and waits for
+// them to exit.
+func (p *Pool) Close() {
+	p.lock.Lock()
+	p.closed = true
+	p.queue = nil
+	p.pending = make(map[world.Position]poolJob)
+	p.wake.Broadcast()
+	p.lock.Unlock()
+	p.workers.Wait()
+}
+
+// next waits for the next pending job. It returns false once the pool is
+// closed.
+func (p *Pool) next() (poolJob, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for {
+		for len(p.queue) > 0 {
+			pos := p.queue[0]
+			p.queue = p.queue[1:]
+			// cancelled jobs leave their position in the queue
+			if job, ok := p.pending[pos]; ok {
+				delete(p.pending, pos)
+				return job, true
+			}
+		}
+		if p.closed {
+			return poolJob{}, false
+		}
+		p.wake.Wait()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.workers.Done()
+	for {
+		job, ok := p.next()
+		if !ok {
+			return
+		}
+		// mesh a copy of the chunk, so that the world may change meanwhile
+		snap := world.TakeSnapshot(job.w, job.pos)
+		res := Result{Target: job.target, Seq: job.seq}
+		Build(snap, p.atlas, job.pos, &res.Layers, job.opts)
+		res.Visibility = ChunkVisibility(snap, job.pos)
+
+		p.lock.Lock()
+		// a newer job for the chunk may have finished first
+		if old, exists := p.done[job.pos]; !p.closed && !job.target.cancelled && (!exists || old.Seq < res.Seq) {
+			p.done[job.pos] = res
+		}
+		p.lock.Unlock()
+	}
+}
@@ -0,0 +1,194 @@
+package mesh
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// gatedWorld holds up the worker meshing it on its first read, until it
+// is opened.
+type gatedWorld struct {
+	mapWorld
+	started chan struct{}
+	open    chan struct{}
+	once    sync.Once
+}
+
+func newGatedWorld() *gatedWorld {
+	return &gatedWorld{mapWorld: mapWorld{}, started: make(chan struct{}), open: make(chan struct{})}
+}
+
+func (g *gatedWorld) GetBlock(x int, y int, z int) world.Block {
+	g.once.Do(func() {
+		close(g.started)
+		<-g.open
+	})
+	return g.mapWorld.GetBlock(x, y, z)
+}
+
+// hold submits a job for the chunk at pos of a gated world, meshed for
+// target, and waits for a worker to start on it.
+func hold(t *testing.T, p *Pool, pos world.Position, target *Target) *gatedWorld {
+	g := newGatedWorld()
+	p.Submit(pos, g, target, Options{})
+	select {
+	case <-g.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no worker started on the job")
+	}
+	return g
+}
+
+// waitResult waits for the result of the chunk at pos and takes it.
+func waitResult(t *testing.T, p *Pool, pos world.Position) Result {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if res, ok := p.Take(pos); ok {
+			return res
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("no result for %v", pos)
+	return Result{}
+}
+
+func TestPoolDedup(t *testing.T) {
+	p := NewPool(testAtlas{}, 1)
+	defer p.Close()
+	gate := hold(t, p, world.Position{X: 9}, &Target{})
+
+	w := mapWorld{}
+	w.SetBlock(1, 1, 1, testStone)
+	target := &Target{}
+	pos := world.Position{}
+	for i := 0; i < 50; i++ {
+		p.Submit(pos, w, target, Options{})
+	}
+	p.lock.Lock()
+	queued, pending := len(p.queue), len(p.pending)
+	p.lock.Unlock()
+	if queued != 1 || pending != 1 {
+		t.Errorf("%d chunks queued and %d pending, want 1", queued, pending)
+	}
+	close(gate.open)
+
+	res := waitResult(t, p, pos)
+	if res.Target != target || res.Seq != 51 || res.Layers.Quads() != 6 {
+		t.Errorf("result for job %d with %d quads, want job 51 with 6", res.Seq, res.Layers.Quads())
+	}
+}
+
+func TestPoolStaleResult(t *testing.T) {
+	p := NewPool(testAtlas{}, 2)
+	defer p.Close()
+	pos := world.Position{}
+	// the first job for the chunk is held up until the second is done
+	old := hold(t, p, pos, &Target{})
+	w := mapWorld{}
+	w.SetBlock(1, 1, 1, testStone)
+	target := &Target{}
+	p.Submit(pos, w, target, Options{})
+	for !p.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// keep the other worker busy, then let the first job finish; once the
+	// worker it held picks up another job, it is done with it
+	busy := hold(t, p, world.Position{X: 1}, &Target{})
+	close(old.open)
+	after := hold(t, p, world.Position{X: 2}, &Target{})
+
+	if res := waitResult(t, p, pos); res.Target != target || res.Seq != 2 {
+		t.Errorf("took job %d, want the newer job 2", res.Seq)
+	}
+	close(busy.open)
+	close(after.open)
+}
+
+func TestPoolCancel(t *testing.T) {
+	p := NewPool(testAtlas{}, 1)
+	defer p.Close()
+	running, queued := world.Position{}, world.Position{X: 1}
+	runningTarget, queuedTarget := &Target{}, &Target{}
+	gate := hold(t, p, running, runningTarget)
+	p.Submit(queued, mapWorld{}, queuedTarget, Options{})
+	p.Cancel(queued, queuedTarget)
+	p.Cancel(running, runningTarget)
+	close(gate.open)
+	// the worker is done with both once it starts on the next job
+	next := hold(t, p, world.Position{X: 2}, &Target{})
+	close(next.open)
+	waitResult(t, p, world.Position{X: 2})
+
+	for _, pos := range []world.Position{running, queued} {
+		if res, ok := p.Take(pos); ok {
+			t.Errorf("result of job %d for %v taken after cancelling it", res.Seq, pos)
+		}
+	}
+
+	// a new target for the chunk gets its results
+	target := &Target{}
+	p.Submit(running, mapWorld{}, target, Options{})
+	if res := waitResult(t, p, running); res.Target != target {
+		t.Errorf("result for the cancelled target")
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	p := NewPool(testAtlas{}, 4)
+	gate := hold(t, p, world.Position{}, &Target{})
+	for x := 1; x < 20; x++ {
+		p.Submit(world.Position{X: x}, mapWorld{}, &Target{}, Options{})
+	}
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	for {
+		p.lock.Lock()
+		closing := p.closed
+		p.lock.Unlock()
+		if closing {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Close waits for the job being worked on
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a job was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(gate.open)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close didn't return")
+	}
+
+	// jobs submitted after closing are dropped
+	p.Submit(world.Position{}, mapWorld{}, &Target{}, Options{})
+	p.lock.Lock()
+	pending := len(p.pending)
+	p.lock.Unlock()
+	if pending != 0 {
+		t.Errorf("%d jobs pending after closing", pending)
+	}
+	if res, ok := p.Take(world.Position{}); ok {
+		t.Errorf("result of the running job kept after closing: %d", res.Seq)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left running after closing, from %d", n, before)
+	}
+}
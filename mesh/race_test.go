@@ -0,0 +1,86 @@
+package mesh
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// testAtlas maps every texture onto the whole sheet.
+type testAtlas struct{}
+
+func (testAtlas) Texture(name string) world.Texture {
+	return world.Texture{MaxU: 1, MaxV: 1}
+}
+
+func newTestWorld(t *testing.T, preset string) (*world.WorldChunked, world.BlockRegistry) {
+	br := world.NewBlockRegistry()
+	br.Register(world.NewBlockSimple("stone", [6]string{}))
+	br.Register(world.NewBlockLight("lamp", [6]string{}, world.MAX_LIGHT))
+	br.Register(world.NewBlockSlab("stone_slab", [6]string{}))
+	br.Register(world.NewBlockLayered("glass", [6]string{}, world.LAYER_TRANSLUCENT))
+	g, err := world.NewGenerator("flat", 1, preset, br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := world.NewWorldChunked(br, g)
+	return &w, br
+}
+
+func TestSnapshotMeshMatchesWorld(t *testing.T) {
+	w, br := newTestWorld(t, "3*stone")
+	w.LoadAround(0, 0, 0, 1, 1000)
+	w.SetBlock(3, 3, 3, br.ByName("lamp"))
+	w.SetBlock(5, 3, 3, br.ByName("glass"))
+	for _, opts := range []Options{{}, {Smooth: true}, {Greedy: true, Smooth: true}} {
+		var a, b Layers
+		Build(w, testAtlas{}, world.Position{}, &a, opts)
+		Build(world.TakeSnapshot(w, world.Position{}), testAtlas{}, world.Position{}, &b, opts)
+		if a.Quads() == 0 || !reflect.DeepEqual(a, b) {
+			t.Errorf("%+v: snapshot mesh has %d quads, world mesh %d", opts, b.Quads(), a.Quads())
+		}
+	}
+}
+
+// TestConcurrentMeshing meshes snapshots on several goroutines while the
+// world is changed; run it with -race. The blocks' models are built by the
+// meshers as they go.
+func TestConcurrentMeshing(t *testing.T) {
+	w, br := newTestWorld(t, "3*stone")
+	w.LoadAround(0, 0, 0, 2, 1000)
+	blocks := []world.Block{nil, br.ByName("lamp"), br.ByName("stone_slab"), br.ByName("glass")}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				p := world.Position{X: i%3 - 1}
+				snap := world.TakeSnapshot(w, p)
+				var m Layers
+				Build(snap, testAtlas{}, p, &m, Options{Greedy: i%2 == 0, Smooth: true})
+				ChunkVisibility(snap, p)
+			}
+		}(i)
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 3000; i++ {
+		x, y, z := r.Intn(48)-16, r.Intn(20), r.Intn(16)
+		w.SetBlock(x, y, z, blocks[r.Intn(len(blocks))])
+		if i%300 == 0 {
+			w.LoadChunk(world.Position{X: i / 300, Y: 3, Z: 5})
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,116 @@
+package mesh
+
+import (
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// Visibility records which pairs of a chunk's faces are connected through
+// the chunk's non-opaque blocks, so that one can be seen through the
+// other. The connection between the faces a and b is bit a*6+b.
+type Visibility uint64
+
+// VISIBLE_ALL connects every pair of faces, as in an empty chunk.
+const VISIBLE_ALL Visibility = 1<<36 - 1
+
+// Connected reports whether the faces a and b are connected.
+func (v Visibility) Connected(a world.Direction, b world.Direction) bool {
+	return v&(1<<(uint(a)*6+uint(b))) != 0
+}
+
+func (v *Visibility) connect(a world.Direction, b world.Direction) {
+	*v |= 1<<(uint(a)*6+uint(b)) | 1<<(uint(b)*6+uint(a))
+}
+
+// ChunkVisibility computes the Visibility of the 16x16x16 chunk at chunk
+// position p by flood filling its non-opaque blocks.
+func ChunkVisibility(w world.BlockAccess, p world.Position) Visibility {
+	var opaque, visited [4096]bool
+	open := 0
+	for i := range opaque {
+		opaque[i] = world.IsOpaque(w.GetBlock(p.X<<4+i&15, p.Y<<4+i>>8, p.Z<<4+(i>>4)&15))
+		if !opaque[i] {
+			open++
+		}
+	}
+	if open == len(opaque) {
+		return VISIBLE_ALL
+	}
+
+	var v Visibility
+	queue := make([]int, 0, open)
+	for start := range opaque {
+		if opaque[start] || visited[start] {
+			continue
+		}
+		// the faces touched by the region around start
+		faces := 0
+		visited[start] = true
+		queue = append(queue[:0], start)
+		for len(queue) > 0 {
+			i := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			pos := [3]int{i & 15, i >> 8, (i >> 4) & 15}
+			for d := world.DOWN; d <= world.FORWARD; d++ {
+				off := d.Offset()
+				x, y, z := pos[0]+off.X, pos[1]+off.Y, pos[2]+off.Z
+				if x < 0 || x > 15 || y < 0 || y > 15 || z < 0 || z > 15 {
+					faces |= 1 << uint(d)
+					continue
+				}
+				j := y<<8 | z<<4 | x
+				if !opaque[j] && !visited[j] {
+					visited[j] = true
+					queue = append(queue, j)
+				}
+			}
+		}
+		for a := world.DOWN; a <= world.FORWARD; a++ {
+			for b := a; b <= world.FORWARD; b++ {
+				if faces&(1<<uint(a)) != 0 && faces&(1<<uint(b)) != 0 {
+					v.connect(a, b)
+				}
+			}
+		}
+	}
+	return v
+}
+
+type visibilityNode struct {
+	pos world.Position
+	// the face the chunk was entered through
+	from world.Direction
+	// the directions travelled to reach the chunk
+	dirs uint8
+}
+
+// VisibleChunks walks the chunks which may be seen from the chunk start,
+// returning them in the order they are reached. A chunk is left through a
+// face only if that face is connected to the one it was entered through,
+// and never in a direction leading back towards start. visibility returns
+// the Visibility of a chunk and inside whether a chunk is in view at all.
+func VisibleChunks(start world.Position, visibility func(world.Position) Visibility, inside func(world.Position) bool) []world.Position {
+	visited := map[world.Position]bool{start: true}
+	queue := []visibilityNode{{pos: start, from: world.UNKNOWN}}
+	var chunks []world.Position
+	for i := 0; i < len(queue); i++ {
+		n := queue[i]
+		chunks = append(chunks, n.pos)
+		v := visibility(n.pos)
+		for d := world.DOWN; d <= world.FORWARD; d++ {
+			if n.dirs&(1<<uint(d^1)) != 0 {
+				continue
+			}
+			if n.from != world.UNKNOWN && !v.Connected(n.from, d) {
+				continue
+			}
+			off := d.Offset()
+			p := world.Position{X: n.pos.X + off.X, Y: n.pos.Y + off.Y, Z: n.pos.Z + off.Z}
+			if visited[p] || !inside(p) {
+				continue
+			}
+			visited[p] = true
+			queue = append(queue, visibilityNode{pos: p, from: d ^ 1, dirs: n.dirs | 1<<uint(d)})
+		}
+	}
+	return chunks
+}
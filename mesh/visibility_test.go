@@ -0,0 +1,161 @@
+package mesh
+
+import (
+	"testing"
+
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// fill sets every block from (x0, y0, z0) to (x1, y1, z1) inclusive to b.
+func fill(w mapWorld, b world.Block, x0, y0, z0, x1, y1, z1 int) {
+	for x := x0; x <= x1; x++ {
+		for y := y0; y <= y1; y++ {
+			for z := z0; z <= z1; z++ {
+				w.SetBlock(x, y, z, b)
+			}
+		}
+	}
+}
+
+// connections lists the pairs of faces connected in v.
+func connections(v Visibility) [][2]world.Direction {
+	var pairs [][2]world.Direction
+	for a := world.DOWN; a <= world.FORWARD; a++ {
+		for b := a; b <= world.FORWARD; b++ {
+			if v.Connected(a, b) {
+				pairs = append(pairs, [2]world.Direction{a, b})
+			}
+		}
+	}
+	return pairs
+}
+
+func TestChunkVisibility(t *testing.T) {
+	p := world.Position{X: -1, Y: 2, Z: 0}
+	w := mapWorld{}
+	if v := ChunkVisibility(w, p); v != VISIBLE_ALL {
+		t.Errorf("empty chunk: connections %v", connections(v))
+	}
+
+	fill(w, testStone, -16, 32, 0, -1, 47, 15)
+	if v := ChunkVisibility(w, p); v != 0 {
+		t.Errorf("sealed stone chunk: connections %v", connections(v))
+	}
+
+	// a cave inside the chunk touching none of its faces
+	fill(w, nil, -12, 36, 4, -4, 44, 12)
+	if v := ChunkVisibility(w, p); v != 0 {
+		t.Errorf("sealed cave: connections %v", connections(v))
+	}
+
+	// a straight tunnel along x
+	fill(w, testStone, -16, 32, 0, -1, 47, 15)
+	fill(w, nil, -16, 40, 8, -1, 40, 8)
+	v := ChunkVisibility(w, p)
+	if !v.Connected(world.LEFT, world.RIGHT) || !v.Connected(world.RIGHT, world.LEFT) {
+		t.Errorf("tunnel: ends not connected")
+	}
+	if n := len(connections(v)); n != 3 {
+		// each open face also connects to itself
+		t.Errorf("tunnel: connections %v", connections(v))
+	}
+
+	// a shaft down from the top which doesn't meet the tunnel
+	fill(w, nil, -14, 42, 2, -14, 47, 2)
+	v = ChunkVisibility(w, p)
+	if v.Connected(world.UP, world.LEFT) || v.Connected(world.UP, world.RIGHT) || v.Connected(world.UP, world.DOWN) {
+		t.Errorf("shaft: connections %v", connections(v))
+	}
+	// and once it does, all three faces are connected
+	fill(w, nil, -14, 40, 2, -14, 41, 8)
+	v = ChunkVisibility(w, p)
+	if !v.Connected(world.UP, world.LEFT) || !v.Connected(world.UP, world.RIGHT) || v.Connected(world.UP, world.DOWN) {
+		t.Errorf("joined shaft: connections %v", connections(v))
+	}
+}
+
+// visibleSet walks the chunks visible from start, where the chunks in vis
+// have the given Visibility and every other is empty.
+func visibleSet(t *testing.T, start world.Position, vis map[world.Position]Visibility, inside func(world.Position) bool) map[world.Position]bool {
+	visibility := func(p world.Position) Visibility {
+		if v, ok := vis[p]; ok {
+			return v
+		}
+		return VISIBLE_ALL
+	}
+	seen := make(map[world.Position]bool)
+	for _, p := range VisibleChunks(start, visibility, inside) {
+		if seen[p] {
+			t.Errorf("chunk %v visited twice", p)
+		}
+		seen[p] = true
+	}
+	return seen
+}
+
+func TestVisibleChunks(t *testing.T) {
+	// two rows of chunks along x, with a sealed chunk in the lower one
+	inside := func(p world.Position) bool {
+		return p.X >= -3 && p.X <= 3 && p.Y >= 0 && p.Y <= 1 && p.Z == 0
+	}
+	vis := map[world.Position]Visibility{{X: 1}: 0}
+	seen := visibleSet(t, world.Position{}, vis, inside)
+	if len(seen) != 12 {
+		t.Errorf("%d chunks visible, want 12", len(seen))
+	}
+	// the sealed chunk is seen, but not through
+	if !seen[world.Position{X: 1}] {
+		t.Errorf("sealed chunk not visible")
+	}
+	// the chunks behind it could only be reached by turning back down
+	// after going up over it
+	for x := 2; x <= 3; x++ {
+		if seen[world.Position{X: x}] {
+			t.Errorf("chunk %d behind the sealed chunk visible", x)
+		}
+		if !seen[world.Position{X: x, Y: 1}] {
+			t.Errorf("chunk %d above the sealed chunk not visible", x)
+		}
+	}
+
+	// the start chunk is seen out of whatever it holds
+	vis[world.Position{}] = 0
+	if seen := visibleSet(t, world.Position{}, vis, inside); len(seen) != 12 {
+		t.Errorf("sealed start: %d chunks visible, want 12", len(seen))
+	}
+}
+
+func TestVisibleChunksTunnel(t *testing.T) {
+	// a straight tunnel along x through stone
+	w := mapWorld{}
+	fill(w, testStone, -48, -16, -16, 47, 31, 31)
+	fill(w, nil, -48, 8, 8, 47, 8, 8)
+	inside := func(p world.Position) bool {
+		return p.X >= -3 && p.X <= 2 && p.Y >= -1 && p.Y <= 1 && p.Z >= -1 && p.Z <= 1
+	}
+	vis := make(map[world.Position]Visibility)
+	for x := -3; x <= 2; x++ {
+		for y := -1; y <= 1; y++ {
+			for z := -1; z <= 1; z++ {
+				p := world.Position{X: x, Y: y, Z: z}
+				vis[p] = ChunkVisibility(w, p)
+			}
+		}
+	}
+	seen := visibleSet(t, world.Position{}, vis, inside)
+	if len(seen) != 10 {
+		t.Errorf("%d chunks visible, want 10", len(seen))
+	}
+	for p := range seen {
+		// the chunks around the start are seen from it, but past it only
+		// the tunnel leads anywhere
+		if p.X != 0 && (p.Y != 0 || p.Z != 0) {
+			t.Errorf("chunk %v visible beside the tunnel", p)
+		}
+	}
+	for x := -3; x <= 2; x++ {
+		if !seen[world.Position{X: x}] {
+			t.Errorf("chunk %d along the tunnel not visible", x)
+		}
+	}
+}
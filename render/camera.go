@@ -0,0 +1,25 @@
+package render
+
+import (
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// Matrices for the camera, loaded by both pipelines and used to cull the
+// chunks outside the view.
+
+// projectionMatrix returns the projection for a viewport of the given size.
+func projectionMatrix(width int32, height int32) world.Mat4 {
+	ratio := float32(height) / float32(width) * 0.01
+	if ratio > 1.0 {
+		return world.Frustum4(-(0.01 / ratio), (0.01 / ratio), -1, 1, 0.01, 512)
+	}
+	return world.Frustum4(-0.01, 0.01, -ratio, ratio, 0.01, 512)
+}
+
+// viewMatrix returns the transformation from world to eye coordinates of
+// the player's view.
+func viewMatrix(player *world.Player) world.Mat4 {
+	return world.RotateX4(player.Pitch).
+		Mul(world.RotateY4(player.Yaw)).
+		Mul(world.Translate4(world.Vec3{-player.Pos[0], -player.Pos[1] - world.EYE_HEIGHT, -player.Pos[2]}))
+}
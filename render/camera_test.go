@@ -0,0 +1,71 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+// boxAt returns a cube of the given size centered on p.
+func boxAt(p world.Vec3, size float32) world.BoundingBox {
+	h := world.Vec3{size / 2, size / 2, size / 2}
+	return world.BoundingBox{Min: p.Translate(h.Scale(-1)), Max: p.Translate(h)}
+}
+
+func TestCameraFrustum(t *testing.T) {
+	const near = 0.01
+	for _, look := range []struct{ yaw, pitch float32 }{{0, 0}, {1.2, 0}, {-2.5, 0.4}, {0.7, -1.1}} {
+		player := &world.Player{Pos: world.Vec3{8, 70, -8}, Yaw: look.yaw, Pitch: look.pitch}
+		f := world.NewFrustum(projectionMatrix(800, 600).Mul(viewMatrix(player)))
+		eye := player.Pos.Translate(world.Vec3{0, world.EYE_HEIGHT, 0})
+		forward := player.LookDirection()
+		at := func(d float32) world.Vec3 {
+			return eye.Translate(forward.Scale(d))
+		}
+
+		cases := []struct {
+			name string
+			box  world.BoundingBox
+			want bool
+		}{
+			{"in front", boxAt(at(20), 1), true},
+			{"far in front", boxAt(at(400), 16), true},
+			{"behind", boxAt(at(-20), 1), false},
+			{"just behind", boxAt(at(-1), 0.5), false},
+			{"straddling the near plane", boxAt(at(near), near), true},
+			{"between the eye and the near plane", boxAt(at(near/2), near/4), false},
+			{"beyond the far plane", boxAt(at(600), 16), false},
+		}
+		for _, c := range cases {
+			if got := f.IntersectsBox(c.box); got != c.want {
+				t.Errorf("yaw %v, pitch %v: box %s: intersects %v, want %v", look.yaw, look.pitch, c.name, got, c.want)
+			}
+		}
+		if !f.ContainsPoint(at(1)) || f.ContainsPoint(at(-1)) {
+			t.Errorf("yaw %v, pitch %v: points before and behind the eye", look.yaw, look.pitch)
+		}
+	}
+}
+
+func TestCameraFrustumSides(t *testing.T) {
+	// looking along -z from the origin, with a 4:3 viewport
+	player := &world.Player{Pos: world.Vec3{0, -world.EYE_HEIGHT, 0}}
+	f := world.NewFrustum(projectionMatrix(800, 600).Mul(viewMatrix(player)))
+	// the near plane spans -0.01 to 0.01 horizontally, so at a distance of
+	// 100 the view is 200 wide and 150 high
+	for _, c := range []struct {
+		p    world.Vec3
+		want bool
+	}{
+		{world.Vec3{95, 0, -100}, true},
+		{world.Vec3{105, 0, -100}, false},
+		{world.Vec3{-105, 0, -100}, false},
+		{world.Vec3{0, 70, -100}, true},
+		{world.Vec3{0, 80, -100}, false},
+		{world.Vec3{0, -80, -100}, false},
+	} {
+		if got := f.ContainsPoint(c.p); got != c.want {
+			t.Errorf("point %v: contained %v, want %v", c.p, got, c.want)
+		}
+	}
+}
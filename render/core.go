@@ -0,0 +1,391 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/asiekierka/reimagined-disco/mesh"
+	"github.com/asiekierka/reimagined-disco/world"
+	gl33 "github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// Attribute locations of the block shader.
+const (
+	attribPosition = iota
+	attribNormal
+	attribColor
+	attribTexcoord
+	attribOrigin
+)
+
+// blockVertexShader and blockFragmentShader draw chunk meshes: textured,
+// lit by the light the mesher stores in the vertex colors, and fogged.
+// Tiled meshes repeat the tile at their origin, as in tiledFragmentShader.
+const blockVertexShader = `
+#version 330 core
+layout(location = 0) in vec3 position;
+layout(location = 1) in vec3 normal;
+layout(location = 2) in vec3 color;
+layout(location = 3) in vec2 texcoord;
+layout(location = 4) in vec2 origin;
+uniform mat4 projection;
+uniform mat4 view;
+out vec3 vColor;
+out vec2 vTexcoord;
+out vec2 vOrigin;
+out float vDistance;
+void main() {
+	vec4 eye = view * vec4(position, 1.0);
+	gl_Position = projection * eye;
+	vColor = color;
+	vTexcoord = texcoord;
+	vOrigin = origin;
+	vDistance = length(eye.xyz);
+}
+` + "\x00"
+
+const blockFragmentShader = `
+#version 330 core
+uniform sampler2D sheet;
+uniform bool tiled;
+uniform vec2 tileSize;
+uniform vec3 fogColor;
+uniform vec2 fogRange;
+uniform float alphaCutoff;
+in vec3 vColor;
+in vec2 vTexcoord;
+in vec2 vOrigin;
+in float vDistance;
+out vec4 fragColor;
+void main() {
+	vec2 uv = vTexcoord;
+	if (tiled) {
+		uv = vOrigin + fract(vTexcoord) * tileSize;
+	}
+	vec4 c = texture(sheet, uv);
+	if (c.a < alphaCutoff) {
+		discard;
+	}
+	float fog = clamp((fogRange.y - vDistance) / (fogRange.y - fogRange.x), 0.0, 1.0);
+	fragColor = vec4(mix(fogColor, c.rgb * vColor, fog), c.a);
+}
+` + "\x00"
+
+// lineVertexShader and lineFragmentShader draw the block highlight.
+const lineVertexShader = `
+#version 330 core
+layout(location = 0) in vec3 position;
+uniform mat4 projection;
+uniform mat4 view;
+void main() {
+	gl_Position = projection * view * vec4(position, 1.0);
+}
+` + "\x00"
+
+const lineFragmentShader = `
+#version 330 core
+uniform vec4 color;
+out vec4 fragColor;
+void main() {
+	fragColor = color;
+}
+` + "\x00"
+
+// corePipeline draws with the OpenGL 3.3 core profile, using vertex array
+// objects and shaders. Quads are drawn as pairs of triangles through an
+// index buffer shared by all meshes.
+type corePipeline struct {
+	blockSheet   uint32
+	tileSize     float32
+	blockProgram uint32
+	blockUniform struct {
+		projection, view, tiled, fogRange, alphaCutoff int32
+	}
+	lineProgram uint32
+	lineUniform struct {
+		projection, view int32
+	}
+	lineVAO      uint32
+	lineVBO      uint32
+	quadIndices  uint32
+	quadCapacity int
+}
+
+func coreCompileShader(source string, shaderType uint32) (uint32, error) {
+	shader := gl33.CreateShader(shaderType)
+	csources, free := gl33.Strs(source)
+	gl33.ShaderSource(shader, 1, csources, nil)
+	free()
+	gl33.CompileShader(shader)
+
+	var status int32
+	gl33.GetShaderiv(shader, gl33.COMPILE_STATUS, &status)
+	if status == gl33.FALSE {
+		var logLength int32
+		gl33.GetShaderiv(shader, gl33.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl33.GetShaderInfoLog(shader, logLength, nil, gl33.Str(log))
+		gl33.DeleteShader(shader)
+		return 0, fmt.Errorf("failed to compile shader: %v", log)
+	}
+	return shader, nil
+}
+
+func coreNewProgram(vertexSource string, fragmentSource string) (uint32, error) {
+	vertexShader, err := coreCompileShader(vertexSource, gl33.VERTEX_SHADER)
+	if err != nil {
+		return 0, err
+	}
+	defer gl33.DeleteShader(vertexShader)
+	fragmentShader, err := coreCompileShader(fragmentSource, gl33.FRAGMENT_SHADER)
+	if err != nil {
+		return 0, err
+	}
+	defer gl33.DeleteShader(fragmentShader)
+
+	program := gl33.CreateProgram()
+	gl33.AttachShader(program, vertexShader)
+	gl33.AttachShader(program, fragmentShader)
+	gl33.LinkProgram(program)
+
+	var status int32
+	gl33.GetProgramiv(program, gl33.LINK_STATUS, &status)
+	if status == gl33.FALSE {
+		var logLength int32
+		gl33.GetProgramiv(program, gl33.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl33.GetProgramInfoLog(program, logLength, nil, gl33.Str(log))
+		gl33.DeleteProgram(program)
+		return 0, fmt.Errorf("failed to link program: %v", log)
+	}
+	return program, nil
+}
+
+func (p *corePipeline) init(sheet *image.NRGBA, tileSize float32) (uint32, error) {
+	if err := gl33.Init(); err != nil {
+		return 0, err
+	}
+	p.tileSize = tileSize
+
+	gl33.GenTextures(1, &p.blockSheet)
+	gl33.BindTexture(gl33.TEXTURE_2D, p.blockSheet)
+	gl33.TexParameteri(gl33.TEXTURE_2D, gl33.TEXTURE_MIN_FILTER, gl33.LINEAR)
+	gl33.TexParameteri(gl33.TEXTURE_2D, gl33.TEXTURE_MAG_FILTER, gl33.NEAREST)
+	gl33.TexParameteri(gl33.TEXTURE_2D, gl33.TEXTURE_WRAP_S, gl33.CLAMP_TO_EDGE)
+	gl33.TexParameteri(gl33.TEXTURE_2D, gl33.TEXTURE_WRAP_T, gl33.CLAMP_TO_EDGE)
+	gl33.TexImage2D(
+		gl33.TEXTURE_2D,
+		0,
+		gl33.RGBA,
+		int32(sheet.Rect.Size().X),
+		int32(sheet.Rect.Size().Y),
+		0,
+		gl33.RGBA,
+		gl33.UNSIGNED_BYTE,
+		gl33.Ptr(sheet.Pix))
+
+	var err error
+	if p.blockProgram, err = coreNewProgram(blockVertexShader, blockFragmentShader); err != nil {
+		return 0, err
+	}
+	p.blockUniform.projection = gl33.GetUniformLocation(p.blockProgram, gl33.Str("projection\x00"))
+	p.blockUniform.view = gl33.GetUniformLocation(p.blockProgram, gl33.Str("view\x00"))
+	p.blockUniform.tiled = gl33.GetUniformLocation(p.blockProgram, gl33.Str("tiled\x00"))
+	p.blockUniform.fogRange = gl33.GetUniformLocation(p.blockProgram, gl33.Str("fogRange\x00"))
+	p.blockUniform.alphaCutoff = gl33.GetUniformLocation(p.blockProgram, gl33.Str("alphaCutoff\x00"))
+	gl33.UseProgram(p.blockProgram)
+	gl33.Uniform1i(gl33.GetUniformLocation(p.blockProgram, gl33.Str("sheet\x00")), 0)
+	gl33.Uniform2f(gl33.GetUniformLocation(p.blockProgram, gl33.Str("tileSize\x00")), tileSize, tileSize)
+	gl33.Uniform3f(gl33.GetUniformLocation(p.blockProgram, gl33.Str("fogColor\x00")), 0.4, 0.6, 0.8)
+
+	if p.lineProgram, err = coreNewProgram(lineVertexShader, lineFragmentShader); err != nil {
+		return 0, err
+	}
+	p.lineUniform.projection = gl33.GetUniformLocation(p.lineProgram, gl33.Str("projection\x00"))
+	p.lineUniform.view = gl33.GetUniformLocation(p.lineProgram, gl33.Str("view\x00"))
+	gl33.UseProgram(p.lineProgram)
+	gl33.Uniform4f(gl33.GetUniformLocation(p.lineProgram, gl33.Str("color\x00")), 1, 1, 1, 1)
+	gl33.UseProgram(0)
+
+	gl33.GenVertexArrays(1, &p.lineVAO)
+	gl33.GenBuffers(1, &p.lineVBO)
+	gl33.BindVertexArray(p.lineVAO)
+	gl33.BindBuffer(gl33.ARRAY_BUFFER, p.lineVBO)
+	gl33.BufferData(gl33.ARRAY_BUFFER, 24*3*4, nil, gl33.DYNAMIC_DRAW)
+	gl33.EnableVertexAttribArray(attribPosition)
+	gl33.VertexAttribPointerWithOffset(attribPosition, 3, gl33.FLOAT, false, 3*4, 0)
+	gl33.BindVertexArray(0)
+
+	gl33.GenBuffers(1, &p.quadIndices)
+	p.reserveQuads(4096)
+
+	gl33.Enable(gl33.DEPTH_TEST)
+	gl33.ClearColor(0.4, 0.6, 0.8, 0)
+	gl33.ClearDepth(1)
+	gl33.DepthFunc(gl33.LEQUAL)
+	return p.blockSheet, nil
+}
+
+// reserveQuads makes the shared index buffer cover at least n quads.
+func (p *corePipeline) reserveQuads(n int) {
+	if n <= p.quadCapacity {
+		return
+	}
+	p.quadCapacity = nearestPow2(n)
+	indices := make([]uint32, 0, p.quadCapacity*6)
+	for i := uint32(0); i < uint32(p.quadCapacity)*4; i += 4 {
+		indices = append(indices, i, i+1, i+2, i, i+2, i+3)
+	}
+	gl33.BindBuffer(gl33.ELEMENT_ARRAY_BUFFER, p.quadIndices)
+	gl33.BufferData(gl33.ELEMENT_ARRAY_BUFFER, 4*len(indices), gl33.Ptr(indices), gl33.STATIC_DRAW)
+}
+
+func (p *corePipeline) fog(start float32, end float32) {
+	gl33.UseProgram(p.blockProgram)
+	gl33.Uniform2f(p.blockUniform.fogRange, start, end)
+	gl33.UseProgram(0)
+}
+
+func (p *corePipeline) tiled() bool {
+	return true
+}
+
+func (p *corePipeline) deinit() {
+	gl33.DeleteTextures(1, &p.blockSheet)
+	gl33.DeleteProgram(p.blockProgram)
+	gl33.DeleteProgram(p.lineProgram)
+	gl33.DeleteVertexArrays(1, &p.lineVAO)
+	gl33.DeleteBuffers(1, &p.lineVBO)
+	gl33.DeleteBuffers(1, &p.quadIndices)
+}
+
+func (p *corePipeline) resize(width int32, height int32) {
+	gl33.Viewport(0, 0, width, height)
+}
+
+func (p *corePipeline) begin(player *world.Player, view world.Mat4, projection world.Mat4) {
+	gl33.Clear(gl33.COLOR_BUFFER_BIT | gl33.DEPTH_BUFFER_BIT)
+	gl33.UseProgram(p.blockProgram)
+	gl33.UniformMatrix4fv(p.blockUniform.projection, 1, false, &projection[0])
+	gl33.UniformMatrix4fv(p.blockUniform.view, 1, false, &view[0])
+	gl33.UseProgram(p.lineProgram)
+	gl33.UniformMatrix4fv(p.lineUniform.projection, 1, false, &projection[0])
+	gl33.UniformMatrix4fv(p.lineUniform.view, 1, false, &view[0])
+}
+
+func (p *corePipeline) end() {
+	gl33.BindVertexArray(0)
+	gl33.UseProgram(0)
+}
+
+func (p *corePipeline) beginBlocks() {
+	gl33.UseProgram(p.blockProgram)
+	gl33.ActiveTexture(gl33.TEXTURE0)
+	gl33.BindTexture(gl33.TEXTURE_2D, p.blockSheet)
+}
+
+func (p *corePipeline) endBlocks() {
+	p.setLayer(world.LAYER_OPAQUE)
+	gl33.BindVertexArray(0)
+}
+
+func (p *corePipeline) setLayer(layer world.RenderLayer) {
+	switch layer {
+	case world.LAYER_OPAQUE:
+		gl33.Uniform1f(p.blockUniform.alphaCutoff, 0)
+		gl33.Disable(gl33.BLEND)
+		gl33.DepthMask(true)
+	case world.LAYER_CUTOUT:
+		gl33.Uniform1f(p.blockUniform.alphaCutoff, 0.5)
+		gl33.Disable(gl33.BLEND)
+		gl33.DepthMask(true)
+	case world.LAYER_TRANSLUCENT:
+		// translucent faces don't hide each other, as they are drawn
+		// back to front
+		gl33.Uniform1f(p.blockUniform.alphaCutoff, 1.0/256)
+		gl33.Enable(gl33.BLEND)
+		gl33.BlendFunc(gl33.SRC_ALPHA, gl33.ONE_MINUS_SRC_ALPHA)
+		gl33.DepthMask(false)
+	}
+}
+
+func (p *corePipeline) upload(l *chunkLayer, m *mesh.Mesh) {
+	if !l.init {
+		gl33.GenVertexArrays(1, &l.vao)
+		gl33.GenBuffers(1, &l.vbo)
+		l.init = true
+	}
+	if len(m.Data) == 0 {
+		return
+	}
+	p.reserveQuads(m.Quads())
+
+	gl33.BindVertexArray(l.vao)
+	gl33.BindBuffer(gl33.ARRAY_BUFFER, l.vbo)
+	gl33.BufferData(gl33.ARRAY_BUFFER, 4*len(m.Data), gl33.Ptr(m.Data), gl33.STATIC_DRAW)
+	stride := int32(m.VertexSize() * 4)
+	gl33.EnableVertexAttribArray(attribPosition)
+	gl33.VertexAttribPointerWithOffset(attribPosition, 3, gl33.FLOAT, false, stride, 0)
+	gl33.EnableVertexAttribArray(attribNormal)
+	gl33.VertexAttribPointerWithOffset(attribNormal, 3, gl33.FLOAT, false, stride, 3*4)
+	gl33.EnableVertexAttribArray(attribColor)
+	gl33.VertexAttribPointerWithOffset(attribColor, 3, gl33.FLOAT, false, stride, 6*4)
+	gl33.EnableVertexAttribArray(attribTexcoord)
+	gl33.VertexAttribPointerWithOffset(attribTexcoord, 2, gl33.FLOAT, false, stride, 9*4)
+	if m.Tiled {
+		gl33.EnableVertexAttribArray(attribOrigin)
+		gl33.VertexAttribPointerWithOffset(attribOrigin, 2, gl33.FLOAT, false, stride, 11*4)
+	} else {
+		gl33.DisableVertexAttribArray(attribOrigin)
+	}
+	gl33.BindBuffer(gl33.ELEMENT_ARRAY_BUFFER, p.quadIndices)
+	gl33.BindVertexArray(0)
+}
+
+func (p *corePipeline) release(l *chunkLayer) {
+	if l.init {
+		gl33.DeleteVertexArrays(1, &l.vao)
+		gl33.DeleteBuffers(1, &l.vbo)
+	}
+}
+
+func (p *corePipeline) draw(l *chunkLayer) {
+	tiled := int32(0)
+	if l.tiled {
+		tiled = 1
+	}
+	gl33.Uniform1i(p.blockUniform.tiled, tiled)
+	gl33.BindVertexArray(l.vao)
+	gl33.DrawElementsWithOffset(gl33.TRIANGLES, l.count/4*6, gl33.UNSIGNED_INT, 0)
+}
+
+func (p *corePipeline) drawHighlight(pos world.Position) {
+	min := world.Vec3{float32(pos.X) - Z_OFFSET, float32(pos.Y) - Z_OFFSET, float32(pos.Z) - Z_OFFSET}
+	max := world.Vec3{float32(pos.X+1) + Z_OFFSET, float32(pos.Y+1) + Z_OFFSET, float32(pos.Z+1) + Z_OFFSET}
+	// the twelve edges of the block
+	lines := make([]float32, 0, 24*3)
+	for a := 0; a < 3; a++ {
+		b, c := (a+1)%3, (a+2)%3
+		for i := 0; i < 4; i++ {
+			var from, to world.Vec3
+			from[a], to[a] = min[a], max[a]
+			from[b], to[b] = min[b], min[b]
+			if i&1 != 0 {
+				from[b], to[b] = max[b], max[b]
+			}
+			from[c], to[c] = min[c], min[c]
+			if i&2 != 0 {
+				from[c], to[c] = max[c], max[c]
+			}
+			lines = append(lines, from[0], from[1], from[2], to[0], to[1], to[2])
+		}
+	}
+
+	gl33.UseProgram(p.lineProgram)
+	gl33.BindVertexArray(p.lineVAO)
+	gl33.BindBuffer(gl33.ARRAY_BUFFER, p.lineVBO)
+	gl33.BufferSubData(gl33.ARRAY_BUFFER, 0, 4*len(lines), gl33.Ptr(lines))
+	gl33.DrawArrays(gl33.LINES, 0, 24)
+	gl33.BindVertexArray(0)
+}
@@ -0,0 +1,240 @@
+package render
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/asiekierka/reimagined-disco/mesh"
+	"github.com/asiekierka/reimagined-disco/world"
+	"github.com/go-gl/gl/v2.1/gl"
+)
+
+// legacyPipeline draws with the fixed-function OpenGL 2.1 pipeline, using
+// a small GLSL 1.20 program only for tiled meshes.
+type legacyPipeline struct {
+	blockSheet      uint32
+	tileSize        float32
+	tiledProgram    uint32
+	tileSizeUniform int32
+}
+
+func (p *legacyPipeline) init(sheet *image.NRGBA, tileSize float32) (uint32, error) {
+	if err := gl.Init(); err != nil {
+		return 0, err
+	}
+	p.tileSize = tileSize
+
+	gl.Enable(gl.TEXTURE_2D)
+	gl.GenTextures(1, &p.blockSheet)
+	gl.BindTexture(gl.TEXTURE_2D, p.blockSheet)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexImage2D(
+		gl.TEXTURE_2D,
+		0,
+		gl.RGBA,
+		int32(sheet.Rect.Size().X),
+		int32(sheet.Rect.Size().Y),
+		0,
+		gl.RGBA,
+		gl.UNSIGNED_BYTE,
+		gl.Ptr(sheet.Pix))
+
+	program, err := newProgram(tiledVertexShader, tiledFragmentShader)
+	if err != nil {
+		fmt.Printf("Greedy meshing unavailable: %v\n", err)
+	} else {
+		p.tiledProgram = program
+		p.tileSizeUniform = gl.GetUniformLocation(program, gl.Str("tileSize\x00"))
+		gl.UseProgram(program)
+		gl.Uniform1i(gl.GetUniformLocation(program, gl.Str("sheet\x00")), 0)
+		gl.UseProgram(0)
+	}
+
+	setupScene()
+	return p.blockSheet, nil
+}
+
+func (p *legacyPipeline) fog(start float32, end float32) {
+	gl.Fogf(gl.FOG_START, start)
+	gl.Fogf(gl.FOG_END, end)
+}
+
+func (p *legacyPipeline) tiled() bool {
+	return p.tiledProgram != 0
+}
+
+func (p *legacyPipeline) deinit() {
+	gl.DeleteTextures(1, &p.blockSheet)
+	if p.tiledProgram != 0 {
+		gl.DeleteProgram(p.tiledProgram)
+	}
+}
+
+func (p *legacyPipeline) resize(width int32, height int32) {
+	projection := projectionMatrix(width, height)
+	gl.Viewport(0, 0, width, height)
+	gl.MatrixMode(gl.PROJECTION)
+	gl.LoadMatrixf(&projection[0])
+	gl.MatrixMode(gl.MODELVIEW)
+	gl.LoadIdentity()
+}
+
+func (p *legacyPipeline) begin(player *world.Player, view world.Mat4, projection world.Mat4) {
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	gl.MatrixMode(gl.MODELVIEW)
+	gl.LoadMatrixf(&view[0])
+	gl.Color4f(1, 1, 1, 1)
+}
+
+func (p *legacyPipeline) end() {
+	gl.LoadIdentity()
+}
+
+func (p *legacyPipeline) beginBlocks() {
+	gl.Enable(gl.TEXTURE_2D)
+	gl.BindTexture(gl.TEXTURE_2D, p.blockSheet)
+
+	gl.EnableClientState(gl.VERTEX_ARRAY)
+	gl.EnableClientState(gl.TEXTURE_COORD_ARRAY)
+	gl.EnableClientState(gl.NORMAL_ARRAY)
+	gl.EnableClientState(gl.COLOR_ARRAY)
+}
+
+func (p *legacyPipeline) endBlocks() {
+	p.setLayer(world.LAYER_OPAQUE)
+	gl.DisableClientState(gl.VERTEX_ARRAY)
+	gl.DisableClientState(gl.TEXTURE_COORD_ARRAY)
+	gl.DisableClientState(gl.NORMAL_ARRAY)
+	gl.DisableClientState(gl.COLOR_ARRAY)
+}
+
+func (p *legacyPipeline) setLayer(layer world.RenderLayer) {
+	switch layer {
+	case world.LAYER_OPAQUE:
+		gl.AlphaFunc(gl.ALWAYS, 0)
+		gl.Disable(gl.BLEND)
+		gl.DepthMask(true)
+	case world.LAYER_CUTOUT:
+		gl.AlphaFunc(gl.GEQUAL, 0.5)
+		gl.Disable(gl.BLEND)
+		gl.DepthMask(true)
+	case world.LAYER_TRANSLUCENT:
+		// translucent faces don't hide each other, as they are drawn
+		// back to front
+		gl.AlphaFunc(gl.GREATER, 0)
+		gl.Enable(gl.BLEND)
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+		gl.DepthMask(false)
+	}
+}
+
+func (p *legacyPipeline) upload(l *chunkLayer, m *mesh.Mesh) {
+	if !l.init {
+		gl.GenBuffers(1, &l.vbo)
+		l.init = true
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, l.vbo)
+	if len(m.Data) > 0 {
+		gl.BufferData(gl.ARRAY_BUFFER, 4*len(m.Data), gl.Ptr(m.Data), gl.STATIC_DRAW)
+	}
+}
+
+func (p *legacyPipeline) release(l *chunkLayer) {
+	if l.init {
+		gl.DeleteBuffers(1, &l.vbo)
+	}
+}
+
+func (p *legacyPipeline) draw(l *chunkLayer) {
+	gl.BindBuffer(gl.ARRAY_BUFFER, l.vbo)
+	if l.tiled {
+		// tiled meshes carry the origin of their tile as a second
+		// set of texture coordinates
+		stride := int32(mesh.TILED_VERTEX_SIZE * 4)
+		gl.UseProgram(p.tiledProgram)
+		gl.Uniform2f(p.tileSizeUniform, p.tileSize, p.tileSize)
+		gl.ClientActiveTexture(gl.TEXTURE1)
+		gl.EnableClientState(gl.TEXTURE_COORD_ARRAY)
+		gl.TexCoordPointer(2, gl.FLOAT, stride, gl.PtrOffset(11*4))
+		gl.ClientActiveTexture(gl.TEXTURE0)
+		gl.VertexPointer(3, gl.FLOAT, stride, gl.PtrOffset(0))
+		gl.TexCoordPointer(2, gl.FLOAT, stride, gl.PtrOffset(9*4))
+		gl.NormalPointer(gl.FLOAT, stride, gl.PtrOffset(3*4))
+		gl.ColorPointer(3, gl.FLOAT, stride, gl.PtrOffset(6*4))
+		gl.DrawArrays(gl.QUADS, 0, l.count)
+		gl.ClientActiveTexture(gl.TEXTURE1)
+		gl.DisableClientState(gl.TEXTURE_COORD_ARRAY)
+		gl.ClientActiveTexture(gl.TEXTURE0)
+		gl.UseProgram(0)
+	} else {
+		gl.VertexPointer(3, gl.FLOAT, 44, gl.PtrOffset(0))
+		gl.TexCoordPointer(2, gl.FLOAT, 44, gl.PtrOffset(9*4))
+		gl.NormalPointer(gl.FLOAT, 44, gl.PtrOffset(3*4))
+		gl.ColorPointer(3, gl.FLOAT, 44, gl.PtrOffset(6*4))
+		gl.DrawArrays(gl.QUADS, 0, l.count)
+	}
+}
+
+func (p *legacyPipeline) drawHighlight(pos world.Position) {
+	xMin := float32(pos.X) - Z_OFFSET
+	xMax := float32(pos.X+1) + Z_OFFSET
+	yMin := float32(pos.Y) - Z_OFFSET
+	yMax := float32(pos.Y+1) + Z_OFFSET
+	zMin := float32(pos.Z) - Z_OFFSET
+	zMax := float32(pos.Z+1) + Z_OFFSET
+
+	gl.Disable(gl.TEXTURE_2D)
+	gl.Enable(gl.LINE_SMOOTH)
+	gl.PolygonMode(gl.FRONT_AND_BACK, gl.LINE)
+	gl.LineWidth(2)
+	gl.Begin(gl.QUADS)
+	gl.Vertex3f(xMin, yMin, zMin)
+	gl.Vertex3f(xMax, yMin, zMin)
+	gl.Vertex3f(xMax, yMin, zMax)
+	gl.Vertex3f(xMin, yMin, zMax)
+	gl.Vertex3f(xMin, yMax, zMin)
+	gl.Vertex3f(xMax, yMax, zMin)
+	gl.Vertex3f(xMax, yMax, zMax)
+	gl.Vertex3f(xMin, yMax, zMax)
+
+	gl.Vertex3f(xMin, yMin, zMin)
+	gl.Vertex3f(xMin, yMax, zMin)
+	gl.Vertex3f(xMin, yMax, zMax)
+	gl.Vertex3f(xMin, yMin, zMax)
+	gl.Vertex3f(xMax, yMin, zMin)
+	gl.Vertex3f(xMax, yMax, zMin)
+	gl.Vertex3f(xMax, yMax, zMax)
+	gl.Vertex3f(xMax, yMin, zMax)
+
+	gl.Vertex3f(xMin, yMin, zMin)
+	gl.Vertex3f(xMin, yMax, zMin)
+	gl.Vertex3f(xMax, yMax, zMin)
+	gl.Vertex3f(xMax, yMin, zMin)
+	gl.Vertex3f(xMin, yMin, zMax)
+	gl.Vertex3f(xMin, yMax, zMax)
+	gl.Vertex3f(xMax, yMax, zMax)
+	gl.Vertex3f(xMax, yMin, zMax)
+	gl.End()
+	gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
+}
+
+func setupScene() {
+	gl.Enable(gl.ALPHA_TEST)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.Disable(gl.LIGHTING)
+
+	gl.ClearColor(0.4, 0.6, 0.8, 0)
+	gl.ClearDepth(1)
+	gl.DepthFunc(gl.LEQUAL)
+
+	gl.Fogi(gl.FOG_MODE, gl.LINEAR)
+	fogcol := []float32{0.4, 0.6, 0.8, 1}
+	gl.Fogfv(gl.FOG_COLOR, &fogcol[0])
+	gl.Enable(gl.FOG)
+
+	ambient := []float32{1, 1, 1, 1}
+	gl.LightModelfv(gl.LIGHT_MODEL_AMBIENT, &ambient[0])
+}
@@ -0,0 +1,539 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	_ "image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/asiekierka/reimagined-disco/mesh"
+	"github.com/asiekierka/reimagined-disco/world"
+)
+
+const Z_OFFSET = 1 / 64
+const DEFAULT_VIEW_DISTANCE = 10
+const MIN_VIEW_DISTANCE = 2
+const MAX_VIEW_DISTANCE = 32
+const DEG_RAD = math.Pi / 180
+
+// Translucent meshes of chunks within SORT_DISTANCE chunks of the eye are
+// sorted again whenever the eye moves SORT_STEP blocks away from where
+// they were last sorted.
+const SORT_DISTANCE = 2
+const SORT_STEP = 1
+
+// pipeline is the part of the renderer which talks to OpenGL. Chunk meshes
+// are drawn between beginBlocks and endBlocks, everything between begin and
+// end.
+type pipeline interface {
+	// init uploads the block sheet and sets up the GL state, returning the
+	// texture the sheet was uploaded to.
+	init(sheet *image.NRGBA, tileSize float32) (uint32, error)
+	// fog sets the distances between which the scene fades into the sky.
+	fog(start float32, end float32)
+	// tiled reports whether tiled meshes can be drawn.
+	tiled() bool
+	deinit()
+	resize(width int32, height int32)
+	begin(player *world.Player, view world.Mat4, projection world.Mat4)
+	end()
+	beginBlocks()
+	endBlocks()
+	// setLayer sets up blending and alpha testing for drawing the meshes
+	// of the given render layer.
+	setLayer(layer world.RenderLayer)
+	// upload copies m to the GPU buffers of l, creating them if needed.
+	upload(l *chunkLayer, m *mesh.Mesh)
+	release(l *chunkLayer)
+	draw(l *chunkLayer)
+	drawHighlight(pos world.Position)
+}
+
+type Render struct {
+	textures map[string]world.Texture
+	buffers map[world.Position]*VertexBuffer
+	pool *mesh.Pool
+	opts mesh.Options
+	pipe pipeline
+	projection world.Mat4
+	viewDistance int
+	drawn int
+	culled int
+	occluded int
+}
+
+// chunkLayer holds the GPU buffers of the mesh of one render layer of a
+// chunk.
+type chunkLayer struct {
+	vbo   uint32
+	vao   uint32
+	count int32
+	init  bool
+	tiled bool
+}
+
+type VertexBuffer struct {
+	world          world.World
+	layers         [world.RENDER_LAYERS]chunkLayer
+	// translucent is kept to be sorted back to front again as the eye
+	// moves; sortedFrom is the eye position it was last sorted for
+	translucent    mesh.Mesh
+	sorted         bool
+	sortedFrom     world.Vec3
+	// visibility is that of the uploaded meshes, seq the number of the
+	// job which built them, or zero before they are uploaded
+	visibility     mesh.Visibility
+	seq            uint64
+	// target is what the mesh pool meshes the chunk for
+	target         mesh.Target
+}
+
+var playerLocal *world.Player
+
+func (v *VertexBuffer) Deinit(r *Render, p world.Position) {
+	r.pool.Cancel(p, &v.target)
+	for i := range v.layers {
+		r.pipe.release(&v.layers[i])
+		v.layers[i] = chunkLayer{}
+	}
+	v.translucent.Reset()
+}
+
+// Refresh uploads the meshes built for the buffer by the mesh pool, if the
+// pool has finished newer ones than the buffer holds.
+func (v *VertexBuffer) Refresh(r *Render, p world.Position) bool {
+	res, ok := r.pool.Take(p)
+	if !ok || res.Target != &v.target || res.Seq < v.seq {
+		return false
+	}
+	for i := range v.layers {
+		if world.RenderLayer(i) != world.LAYER_TRANSLUCENT {
+			v.upload(r, world.RenderLayer(i), &res.Layers[i])
+		}
+	}
+	// the translucent mesh is uploaded once it is sorted for the eye
+	v.translucent = res.Layers[world.LAYER_TRANSLUCENT]
+	v.sorted = false
+	v.visibility = res.Visibility
+	v.seq = res.Seq
+	return true
+}
+
+func (v *VertexBuffer) upload(r *Render, layer world.RenderLayer, m *mesh.Mesh) {
+	l := &v.layers[layer]
+	r.pipe.upload(l, m)
+	l.count = m.Count
+	l.tiled = m.Tiled
+}
+
+// sort uploads the translucent mesh sorted back to front as seen from eye,
+// unless it was sorted for a position close enough already.
+func (v *VertexBuffer) sort(r *Render, p world.Position, eye world.Vec3) {
+	if v.sorted {
+		if v.translucent.Count == 0 || !world.InViewDistance(chunkAt(eye), p, SORT_DISTANCE) {
+			return
+		}
+		d := eye.Translate(v.sortedFrom.Scale(-1))
+		if d[0]*d[0]+d[1]*d[1]+d[2]*d[2] < SORT_STEP*SORT_STEP {
+			return
+		}
+	}
+	v.translucent.SortQuads(eye)
+	v.upload(r, world.LAYER_TRANSLUCENT, &v.translucent)
+	v.sorted = true
+	v.sortedFrom = eye
+}
+
+// empty reports whether the buffer has nothing to draw.
+func (v *VertexBuffer) empty() bool {
+	for _, l := range v.layers {
+		if l.count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Draw draws the mesh of the given render layer.
+func (v *VertexBuffer) Draw(r *Render, layer world.RenderLayer) {
+	if l := &v.layers[layer]; l.count > 0 && l.init {
+		r.pipe.draw(l)
+	}
+}
+
+func nearestPow2(n int) int {
+	t := uint32(n - 1)
+	t |= t >> 1
+	t |= t >> 2
+	t |= t >> 4
+	t |= t >> 8
+	t |= t >> 16
+	return int(t + 1)
+}
+
+// Init sets up the renderer for the current GL context, drawing with the
+// OpenGL 3.3 core profile if core is set and with the fixed-function
+// pipeline otherwise.
+func (r *Render) Init(width int32, height int32, debugtextures bool, core bool) error {
+	r.buffers = make(map[world.Position]*VertexBuffer, 1000)
+	r.textures = make(map[string]world.Texture)
+	sheet, tileSize := r.initTextures(debugtextures)
+
+	if core {
+		r.pipe = &corePipeline{}
+	} else {
+		r.pipe = &legacyPipeline{}
+	}
+	binding, err := r.pipe.init(sheet, tileSize)
+	if err != nil {
+		return err
+	}
+
+	// one worker per CPU, leaving one for the main thread
+	workers := runtime.GOMAXPROCS(0) - 1
+	if workers < 1 {
+		workers = 1
+	}
+	r.pool = mesh.NewPool(r, workers)
+
+	for name, tex := range r.textures {
+		tex.Binding = binding
+		r.textures[name] = tex
+	}
+	r.SetViewDistance(DEFAULT_VIEW_DISTANCE)
+	r.Resize(width, height)
+	return nil
+}
+
+// initTextures packs the textures into a single sheet, returning it and
+// the size of a tile in texture coordinates. The sheet keeps straight
+// alpha, as translucent blocks are blended with it.
+func (r *Render) initTextures(debugtextures bool) (*image.NRGBA, float32) {
+	// TODO: not assume that all textures are going to be 16x16
+	files, _ := ioutil.ReadDir("./textures/")
+	textures := make(map[string]image.Image, 256)
+	for _, texfn := range files {
+		if texfn.IsDir() {
+			continue
+		}
+		imgFile, err := os.Open("./textures/" + texfn.Name())
+		if err == nil {
+			img, _, err := image.Decode(imgFile)
+			if err == nil {
+				textures[texfn.Name()] = img
+			}
+			imgFile.Close()
+		}
+	}
+
+	countSide := 1
+	for countSide*countSide < len(textures) {
+		countSide *= 2
+	}
+	rgba := image.NewNRGBA(image.Rectangle{image.ZP, image.Pt(countSide << 4, countSide << 4)})
+	pos := 0
+
+	fmt.Printf("Initialized texture of size %d x %d\n", countSide << 4, countSide << 4)
+
+	for name, img := range textures {
+		pX := (pos % countSide)
+		pY := (pos / countSide)
+		dp := image.Pt(pX << 4, pY << 4)
+		pos++
+
+		draw.Draw(rgba, image.Rectangle{dp, dp.Add(image.Pt(16, 16))}, img, image.ZP, draw.Src)
+
+		fmt.Printf("Loaded texture %s @ %d, %d\n", name, pX, pY)
+		r.textures[name] = world.Texture{
+			MinU: float32(pX) / float32(countSide),
+			MaxU: float32(pX + 1) / float32(countSide),
+			MinV: float32(pY) / float32(countSide),
+			MaxV: float32(pY + 1) / float32(countSide),
+		}
+	}
+
+	if debugtextures {
+		tmpFile, _ := os.Create("./blockSheet.png")
+		png.Encode(tmpFile, rgba) 
+		tmpFile.Close()
+	}
+
+	return rgba, 1 / float32(countSide)
+}
+
+// ViewDistance returns the radius, in chunks, of the area drawn around the
+// player.
+func (r *Render) ViewDistance() int {
+	return r.viewDistance
+}
+
+// SetViewDistance changes the radius of the area drawn around the player,
+// clamped to between MIN_VIEW_DISTANCE and MAX_VIEW_DISTANCE chunks.
+func (r *Render) SetViewDistance(distance int) {
+	if distance < MIN_VIEW_DISTANCE {
+		distance = MIN_VIEW_DISTANCE
+	} else if distance > MAX_VIEW_DISTANCE {
+		distance = MAX_VIEW_DISTANCE
+	}
+	r.viewDistance = distance
+	r.pipe.fog(float32(distance * 16 - 32), float32(distance * 16 - 8))
+}
+
+// Greedy reports whether chunks are meshed with the greedy mesher.
+func (r *Render) Greedy() bool {
+	return r.opts.Greedy
+}
+
+// SetGreedy switches between the greedy and the per-face mesher, remeshing
+// every chunk. It returns false if greedy meshes can't be drawn.
+func (r *Render) SetGreedy(greedy bool) bool {
+	if greedy && !r.pipe.tiled() {
+		return false
+	}
+	opts := r.opts
+	opts.Greedy = greedy
+	r.setOptions(opts)
+	return true
+}
+
+// Smooth reports whether chunks are meshed with smooth lighting.
+func (r *Render) Smooth() bool {
+	return r.opts.Smooth
+}
+
+// SetSmooth switches smooth lighting and ambient occlusion on or off,
+// remeshing every chunk.
+func (r *Render) SetSmooth(smooth bool) {
+	opts := r.opts
+	opts.Smooth = smooth
+	r.setOptions(opts)
+}
+
+func (r *Render) setOptions(opts mesh.Options) {
+	if r.opts != opts {
+		r.opts = opts
+		for p := range r.buffers {
+			r.markForUpdate(p)
+		}
+	}
+}
+
+func (r *Render) Texture(name string) world.Texture {
+	return r.textures[name]
+}
+
+func (r *Render) markForUpdate(p world.Position) {
+	buf, exists := r.buffers[p]
+	if exists {
+		r.pool.Submit(p, buf.world, &buf.target, r.opts)
+	}
+}
+
+func (r *Render) OnRenderUpdate(x int, y int, z int) {
+	cx := x >> 4
+	cy := y >> 4
+	cz := z >> 4
+	r.markForUpdate(world.Position{X: cx, Y: cy, Z: cz})
+	if x & 15 == 0 {
+		r.markForUpdate(world.Position{X: cx - 1, Y: cy, Z: cz})
+	} else if x & 15 == 15 {
+		r.markForUpdate(world.Position{X: cx + 1, Y: cy, Z: cz})
+	}
+	if y & 15 == 0 {
+		r.markForUpdate(world.Position{X: cx, Y: cy - 1, Z: cz})
+	} else if y & 15 == 15 {
+		r.markForUpdate(world.Position{X: cx, Y: cy + 1, Z: cz})
+	}
+	if z & 15 == 0 {
+		r.markForUpdate(world.Position{X: cx, Y: cy, Z: cz - 1})
+	} else if z & 15 == 15 {
+		r.markForUpdate(world.Position{X: cx, Y: cy, Z: cz + 1})
+	}
+}
+
+func (r *Render) OnChunkLoad(p world.Position) {
+	r.markForUpdate(world.Position{X: p.X - 1, Y: p.Y, Z: p.Z})
+	r.markForUpdate(world.Position{X: p.X + 1, Y: p.Y, Z: p.Z})
+	r.markForUpdate(world.Position{X: p.X, Y: p.Y - 1, Z: p.Z})
+	r.markForUpdate(world.Position{X: p.X, Y: p.Y + 1, Z: p.Z})
+	r.markForUpdate(world.Position{X: p.X, Y: p.Y, Z: p.Z - 1})
+	r.markForUpdate(world.Position{X: p.X, Y: p.Y, Z: p.Z + 1})
+}
+
+func (r *Render) Deinit() {
+	r.pool.Close()
+	for pos, buf := range r.buffers {
+		buf.Deinit(r, pos)
+		delete(r.buffers, pos)
+	}
+	r.pipe.deinit()
+}
+
+// chunkAt returns the position of the chunk containing v.
+func chunkAt(v world.Vec3) world.Position {
+	return world.Position{
+		X: int(math.Floor(float64(v[0]))) >> 4,
+		Y: int(math.Floor(float64(v[1]))) >> 4,
+		Z: int(math.Floor(float64(v[2]))) >> 4,
+	}
+}
+
+// isUsefulChunk reports whether the buffer of the chunk at p is worth
+// keeping. Chunks are kept a little beyond the view distance so that they
+// aren't dropped and remeshed as the player walks back and forth.
+func (r *Render) isUsefulChunk(player *world.Player, p world.Position) bool {
+	return world.InViewDistance(chunkAt(player.Pos), p, r.viewDistance + 1)
+}
+
+func dynamicChunkRender(r *Render, w world.World, p world.Position, vbo *VertexBuffer) {
+}
+
+// ChunkStats returns how many chunks were drawn in the last frame, how
+// many were skipped for being outside the view frustum and how many for
+// being hidden behind opaque blocks.
+func (r *Render) ChunkStats() (int, int, int) {
+	return r.drawn, r.culled, r.occluded
+}
+
+// chunkBox returns the bounding box of the chunk at chunk position p.
+func chunkBox(p world.Position) world.BoundingBox {
+	min := world.Vec3{float32(p.X << 4), float32(p.Y << 4), float32(p.Z << 4)}
+	return world.BoundingBox{Min: min, Max: min.Translate(world.Vec3{16, 16, 16})}
+}
+
+// chunkDistanceSq returns the squared distance between eye and the center
+// of the chunk at p.
+func chunkDistanceSq(eye world.Vec3, p world.Position) float32 {
+	d := chunkBox(p).Min.Translate(world.Vec3{8, 8, 8}).Translate(eye.Scale(-1))
+	return d[0]*d[0] + d[1]*d[1] + d[2]*d[2]
+}
+
+func (r *Render) drawBlockVBOs(player *world.Player, w world.World, frustum *world.Frustum) {
+	center := chunkAt(player.Pos)
+	eye := player.Pos.Translate(world.Vec3{0, world.EYE_HEIGHT, 0})
+
+	// remove unused VBOs
+	for pos, buf := range r.buffers {
+		if !r.isUsefulChunk(player, pos) {
+			buf.Deinit(r, pos)
+			delete(r.buffers, pos)
+		}
+	}
+
+	r.pipe.beginBlocks()
+
+	maxRefresh := 8
+
+	// refresh VBOs, nearest first, and add the missing ones
+	var missing []world.Position
+	ready := r.pool.Ready()
+	for _, off := range world.ViewOffsets(r.viewDistance) {
+		pos := world.Position{X: center.X + off.X, Y: center.Y + off.Y, Z: center.Z + off.Z}
+		if !w.IsLoaded(pos.X << 4, pos.Y << 4, pos.Z << 4) {
+			continue
+		}
+
+		if buf, exists := r.buffers[pos]; !exists {
+			missing = append(missing, pos)
+		} else if maxRefresh > 0 && ready && buf.Refresh(r, pos) {
+			maxRefresh--
+		}
+	}
+
+	// mesh the missing chunks in front of the player first
+	forward := player.LookDirection()
+	sort.Slice(missing, func(i, j int) bool {
+		oi := world.Position{X: missing[i].X - center.X, Y: missing[i].Y - center.Y, Z: missing[i].Z - center.Z}
+		oj := world.Position{X: missing[j].X - center.X, Y: missing[j].Y - center.Y, Z: missing[j].Z - center.Z}
+		return world.ViewPriority(oi, forward) < world.ViewPriority(oj, forward)
+	})
+	for _, pos := range missing {
+		v := VertexBuffer{world: w}
+		r.buffers[pos] = &v
+		r.pool.Submit(pos, w, &v.target, r.opts)
+	}
+
+	// render the chunks which can be seen from the camera's chunk
+	visible := mesh.VisibleChunks(chunkAt(eye), func(pos world.Position) mesh.Visibility {
+		if buf, exists := r.buffers[pos]; exists && buf.seq != 0 {
+			return buf.visibility
+		}
+		// chunks which have not been meshed yet may be seen through
+		return mesh.VISIBLE_ALL
+	}, func(pos world.Position) bool {
+		return world.InViewDistance(center, pos, r.viewDistance) && frustum.IntersectsBox(chunkBox(pos))
+	})
+
+	drawn := make(map[world.Position]bool, len(visible))
+	for _, pos := range visible {
+		if _, exists := r.buffers[pos]; exists {
+			drawn[pos] = true
+		}
+	}
+	for _, layer := range []world.RenderLayer{world.LAYER_OPAQUE, world.LAYER_CUTOUT} {
+		r.pipe.setLayer(layer)
+		for _, pos := range visible {
+			if buf, exists := r.buffers[pos]; exists {
+				buf.Draw(r, layer)
+			}
+		}
+	}
+
+	// blend the translucent meshes over the rest of the scene, from the
+	// farthest chunk to the nearest
+	sort.SliceStable(visible, func(i, j int) bool {
+		return chunkDistanceSq(eye, visible[i]) > chunkDistanceSq(eye, visible[j])
+	})
+	r.pipe.setLayer(world.LAYER_TRANSLUCENT)
+	for _, pos := range visible {
+		if buf, exists := r.buffers[pos]; exists {
+			buf.sort(r, pos, eye)
+			buf.Draw(r, world.LAYER_TRANSLUCENT)
+		}
+	}
+
+	r.drawn, r.culled, r.occluded = 0, 0, 0
+	for pos, buf := range r.buffers {
+		if buf.empty() {
+			continue
+		}
+		if drawn[pos] {
+			r.drawn++
+		} else if !frustum.IntersectsBox(chunkBox(pos)) {
+			r.culled++
+		} else {
+			r.occluded++
+		}
+	}
+
+	r.pipe.endBlocks()
+}
+
+func (r *Render) Render(player *world.Player, w world.World) {
+	// --- INIT ---
+	playerLocal = player
+
+	view := viewMatrix(player)
+	frustum := world.NewFrustum(r.projection.Mul(view))
+	r.pipe.begin(player, view, r.projection)
+	r.drawBlockVBOs(player, w, &frustum)
+
+	// draw block wireframe
+	if pos, exists := player.GetHoverCoords(w); exists {
+		r.pipe.drawHighlight(pos)
+	}
+	r.pipe.end()
+
+	// --- CLEANUP ---
+}
+
+func (r *Render) Resize(width int32, height int32) {
+	r.projection = projectionMatrix(width, height)
+	r.pipe.resize(width, height)
+}
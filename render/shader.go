@@ -0,0 +1,88 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/gl/v2.1/gl"
+)
+
+// tiledVertexShader and tiledFragmentShader draw tiled meshes, whose
+// texture coordinates count tiles from the origin of a tile in the block
+// sheet, repeating the tile across quads spanning several blocks. Fog
+// follows the fixed-function fog state.
+const tiledVertexShader = `
+#version 120
+varying vec2 local;
+varying vec2 origin;
+void main() {
+	gl_Position = ftransform();
+	gl_FrontColor = gl_Color;
+	gl_FogFragCoord = length((gl_ModelViewMatrix * gl_Vertex).xyz);
+	local = gl_MultiTexCoord0.st;
+	origin = gl_MultiTexCoord1.st;
+}
+` + "\x00"
+
+const tiledFragmentShader = `
+#version 120
+uniform sampler2D sheet;
+uniform vec2 tileSize;
+varying vec2 local;
+varying vec2 origin;
+void main() {
+	vec4 c = texture2D(sheet, origin + fract(local) * tileSize) * gl_Color;
+	float fog = clamp((gl_Fog.end - gl_FogFragCoord) * gl_Fog.scale, 0.0, 1.0);
+	gl_FragColor = vec4(mix(gl_Fog.color.rgb, c.rgb, fog), c.a);
+}
+` + "\x00"
+
+func compileShader(source string, shaderType uint32) (uint32, error) {
+	shader := gl.CreateShader(shaderType)
+	csources, free := gl.Strs(source)
+	gl.ShaderSource(shader, 1, csources, nil)
+	free()
+	gl.CompileShader(shader)
+
+	var status int32
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
+		return 0, fmt.Errorf("failed to compile shader: %v", log)
+	}
+	return shader, nil
+}
+
+func newProgram(vertexSource string, fragmentSource string) (uint32, error) {
+	vertexShader, err := compileShader(vertexSource, gl.VERTEX_SHADER)
+	if err != nil {
+		return 0, err
+	}
+	defer gl.DeleteShader(vertexShader)
+	fragmentShader, err := compileShader(fragmentSource, gl.FRAGMENT_SHADER)
+	if err != nil {
+		return 0, err
+	}
+	defer gl.DeleteShader(fragmentShader)
+
+	program := gl.CreateProgram()
+	gl.AttachShader(program, vertexShader)
+	gl.AttachShader(program, fragmentShader)
+	gl.LinkProgram(program)
+
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		gl.DeleteProgram(program)
+		return 0, fmt.Errorf("failed to link program: %v", log)
+	}
+	return program, nil
+}
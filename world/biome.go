@@ -0,0 +1,114 @@
+package world
+
+import (
+	"math"
+
+	"github.com/larspensjo/Go-simplex-noise/simplexnoise"
+)
+
+// Biome describes the climate of an area of the world, and with it the
+// blocks covering its surface and the shape of its terrain.
+type Biome struct {
+	name        string
+	temperature float64
+	humidity    float64
+	surface     string
+	subsurface  string
+	// height is the base terrain height and heightScale multiplies the
+	// heightmap noise, whose octaves are weighted by octaveWeights.
+	height        float64
+	heightScale   float64
+	octaveWeights [len(simplexFrequencies)]float64
+	// snowLine, if non-zero, is the height above which the surface is
+	// covered with snow.
+	snowLine int
+}
+
+var (
+	BiomePlains = &Biome{
+		name: "plains", temperature: 0.2, humidity: 0.1,
+		surface: "grass", subsurface: "dirt",
+		height: 64, heightScale: 0.6,
+		octaveWeights: [6]float64{1, 1, 0.8, 0.6, 0.4, 0.4},
+	}
+	BiomeDesert = &Biome{
+		name: "desert", temperature: 0.7, humidity: -0.6,
+		surface: "sand", subsurface: "sandstone",
+		height: 62, heightScale: 0.4,
+		octaveWeights: [6]float64{1, 1.2, 1, 0.3, 0.2, 0.2},
+	}
+	BiomeSnowy = &Biome{
+		name: "snowy", temperature: -0.7, humidity: 0,
+		surface: "snow", subsurface: "dirt",
+		height: 66, heightScale: 0.7,
+		octaveWeights: [6]float64{1, 1, 1, 1, 1, 1},
+	}
+	BiomeMountains = &Biome{
+		name: "mountains", temperature: -0.1, humidity: 0.6,
+		surface: "grass", subsurface: "dirt",
+		height: 76, heightScale: 1.5,
+		octaveWeights: [6]float64{1, 1, 1.2, 1.4, 1.4, 1},
+		snowLine:      100,
+	}
+
+	Biomes = [...]*Biome{BiomePlains, BiomeDesert, BiomeSnowy, BiomeMountains}
+)
+
+const (
+	CLIMATE_FREQUENCY = 0.0015
+	// BIOME_BLEND controls how far from a biome's climate its terrain
+	// shape still has an influence; larger values blend over wider areas.
+	BIOME_BLEND = 0.3
+)
+
+func (b *Biome) Name() string {
+	return b.name
+}
+
+// BiomeSource is implemented by generators which place biomes in the world.
+type BiomeSource interface {
+	GetBiome(x int, z int) *Biome
+}
+
+// climate computes the temperature and humidity of a column, both roughly
+// in the range [-1, 1].
+func (g *GeneratorSimplex) climate(x int, z int) (float64, float64) {
+	fx := float64(x) * CLIMATE_FREQUENCY
+	fz := float64(z) * CLIMATE_FREQUENCY
+	t := simplexnoise.Noise3(fx, fz, g.climateOffsets[0]) + 0.5*simplexnoise.Noise3(fx*2, fz*2, g.climateOffsets[0])
+	h := simplexnoise.Noise3(fx, fz, g.climateOffsets[1]) + 0.5*simplexnoise.Noise3(fx*2, fz*2, g.climateOffsets[1])
+	return t, h
+}
+
+// biomeWeights returns the influence of every biome in Biomes over a column,
+// summing up to 1.
+func (g *GeneratorSimplex) biomeWeights(x int, z int) [len(Biomes)]float64 {
+	var weights [len(Biomes)]float64
+	t, h := g.climate(x, z)
+	total := 0.0
+	for i, b := range Biomes {
+		dt := t - b.temperature
+		dh := h - b.humidity
+		weights[i] = math.Exp(-(dt*dt + dh*dh) / (BIOME_BLEND * BIOME_BLEND))
+		total += weights[i]
+	}
+	if total == 0 {
+		weights[0] = 1
+		return weights
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+	return weights
+}
+
+func (g *GeneratorSimplex) GetBiome(x int, z int) *Biome {
+	weights := g.biomeWeights(x, z)
+	best := 0
+	for i := range weights {
+		if weights[i] > weights[best] {
+			best = i
+		}
+	}
+	return Biomes[best]
+}
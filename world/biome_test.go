@@ -0,0 +1,65 @@
+package world
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBiomeWeights(t *testing.T) {
+	g := NewGeneratorSimplex(newTestRegistry(testBlockNames), 3)
+	seen := make(map[*Biome]bool)
+	for x := -8000; x < 8000; x += 97 {
+		for z := -8000; z < 8000; z += 89 {
+			weights := g.biomeWeights(x, z)
+			total := 0.0
+			best := 0
+			for i, w := range weights {
+				if w < 0 {
+					t.Fatalf("%d, %d: negative weight %v for %s", x, z, w, Biomes[i].Name())
+				}
+				if w > weights[best] {
+					best = i
+				}
+				total += w
+			}
+			if math.Abs(total-1) > 1e-9 {
+				t.Fatalf("%d, %d: weights add up to %v", x, z, total)
+			}
+			b := g.GetBiome(x, z)
+			if b != Biomes[best] {
+				t.Fatalf("%d, %d: biome %s, but %s weighs the most", x, z, b.Name(), Biomes[best].Name())
+			}
+			seen[b] = true
+		}
+	}
+	for _, b := range Biomes {
+		if !seen[b] {
+			t.Errorf("biome %s never chosen", b.Name())
+		}
+	}
+}
+
+func TestBiomeSurface(t *testing.T) {
+	br := newTestRegistry(testBlockNames)
+	g := NewGeneratorSimplex(br, 3)
+	for x := -4000; x < 4000; x += 397 {
+		for z := -4000; z < 4000; z += 401 {
+			height, bi := g.column(x, z)
+			if height != g.SurfaceHeight(x, z) {
+				t.Fatalf("%d, %d: surface height %d, column height %d", x, z, g.SurfaceHeight(x, z), height)
+			}
+			b := Biomes[bi]
+			if b != g.GetBiome(x, z) {
+				t.Fatalf("%d, %d: dominant biome %s, GetBiome %s", x, z, b.Name(), g.GetBiome(x, z).Name())
+			}
+			want := b.surface
+			if b.snowLine != 0 && height >= b.snowLine {
+				want = "snow"
+			}
+			p := chunkPos(x, height, z)
+			if got := generateChunk(g, p).get(chunkIndex(x, height, z)); got == nil || got.Name() != want {
+				t.Errorf("%d, %d: surface %v in %s, want %s", x, z, got, b.Name(), want)
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package world
+
+// Block is a single state of a block type. Blocks with properties have one
+// Block value per combination of property values, all sharing the same name;
+// WithState switches between them.
+type Block interface {
+	New() Block
+	Name() string
+	GetModel(a TextureAtlas) Model
+	GetBoundingBox() BoundingBox
+	IsSideSolid(d Direction) bool
+	LightEmission() uint8
+	RenderLayer() RenderLayer
+	Properties() []Property
+	State() BlockState
+	WithState(s BlockState) Block
+}
+
+// RenderLayer is the pass a block is drawn in.
+type RenderLayer int
+
+const (
+	// LAYER_OPAQUE blocks are drawn first, without blending.
+	LAYER_OPAQUE RenderLayer = iota
+	// LAYER_CUTOUT blocks have fully transparent holes, like leaves.
+	LAYER_CUTOUT
+	// LAYER_TRANSLUCENT blocks are blended with what is behind them, like
+	// glass, and drawn last, back to front.
+	LAYER_TRANSLUCENT
+)
+
+// RENDER_LAYERS is the number of render layers.
+const RENDER_LAYERS = 3
+
+// IsSideSolid reports whether the side d of b, which may be nil for air,
+// is solid.
+func IsSideSolid(b Block, d Direction) bool {
+	return b != nil && b.IsSideSolid(d)
+}
+
+// IsOpaque reports whether every side of b is solid, so that neither light
+// nor sight can pass through it.
+func IsOpaque(b Block) bool {
+	for d := DOWN; d <= FORWARD; d++ {
+		if !IsSideSolid(b, d) {
+			return false
+		}
+	}
+	return true
+}
+
+type BlockRegistry struct {
+	idBlock []Block
+	nameBlock map[string]Block
+	nameId map[string]int
+	maxId int
+}
+
+type BlockSimple struct {
+	name string
+	textures [6]string
+	light uint8
+	layer RenderLayer
+	models modelCache
+}
+
+func NewBlockRegistry() BlockRegistry {
+	return BlockRegistry{
+		idBlock: make([]Block, 256, 256),
+		nameId: make(map[string]int, 256),
+		nameBlock: make(map[string]Block, 256),
+		maxId: 1,
+	}
+}
+
+func (b *BlockRegistry) ByName(s string) Block {
+	return b.nameBlock[s]
+}
+
+func (b *BlockRegistry) ByID(i int) Block {
+	return b.idBlock[i]
+}
+
+func (b *BlockRegistry) GetID(bb Block) int {
+	return b.nameId[bb.Name()]
+}
+
+func (b *BlockRegistry) allocID() int {
+	b.maxId++
+	for b.idBlock[b.maxId] != nil {
+		b.maxId++
+	}
+	return b.maxId - 1
+}
+
+func (b *BlockRegistry) Register(bb Block) {
+	id := b.allocID()
+	b.nameBlock[bb.Name()] = bb
+	b.nameId[bb.Name()] = id
+	b.idBlock[id] = bb
+}
+
+func NewBlockSimple(name string, textures [6]string) *BlockSimple {
+	return &BlockSimple{name: name, textures: textures}
+}
+
+// NewBlockLight creates a full cube block giving off light of the given
+// level, up to MAX_LIGHT.
+func NewBlockLight(name string, textures [6]string, light uint8) *BlockSimple {
+	return &BlockSimple{name: name, textures: textures, light: light}
+}
+
+// NewBlockLayered creates a full cube block drawn in the given render
+// layer. Blocks which are not opaque don't hide their neighbors' faces and
+// let light through.
+func NewBlockLayered(name string, textures [6]string, layer RenderLayer) *BlockSimple {
+	return &BlockSimple{name: name, textures: textures, layer: layer}
+}
+
+func (b *BlockSimple) Name() string {
+	return b.name
+}
+
+func (b *BlockSimple) GetModel(a TextureAtlas) Model {
+	return b.models.get(a, func() Model {
+		return NewFullCubeModel([6]Texture{
+			a.Texture(b.textures[0]),
+			a.Texture(b.textures[1]),
+			a.Texture(b.textures[2]),
+			a.Texture(b.textures[3]),
+			a.Texture(b.textures[4]),
+			a.Texture(b.textures[5]),
+		})
+	})
+}
+
+func (b *BlockSimple) GetBoundingBox() BoundingBox {
+	return BoundingBox{Vec3{0,0,0}, Vec3{1,1,1}}
+}
+
+func (b *BlockSimple) IsSideSolid(d Direction) bool {
+	return b.layer == LAYER_OPAQUE
+}
+
+func (b *BlockSimple) LightEmission() uint8 {
+	return b.light
+}
+
+func (b *BlockSimple) RenderLayer() RenderLayer {
+	return b.layer
+}
+
+func (b *BlockSimple) New() Block {
+	return b
+}
+
+func (b *BlockSimple) Properties() []Property {
+	return nil
+}
+
+func (b *BlockSimple) State() BlockState {
+	return 0
+}
+
+func (b *BlockSimple) WithState(s BlockState) Block {
+	return b
+}
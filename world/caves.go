@@ -0,0 +1,107 @@
+package world
+
+import (
+	"math"
+
+	"github.com/larspensjo/Go-simplex-noise/simplexnoise"
+)
+
+const (
+	CAVE_FREQUENCY = 0.03
+	// CAVE_THRESHOLD is the width of the band around zero, in both noise
+	// fields, which gets carved out; tunnels follow the intersection of
+	// the two bands.
+	CAVE_THRESHOLD = 0.09
+	// CAVE_MIN_DEPTH is how many blocks below the surface caves start.
+	CAVE_MIN_DEPTH = 4
+)
+
+// OreVein describes one kind of ore deposit. Each chunk overlapping
+// [MinY, MaxY] receives up to PerChunk veins of about Size blocks of the
+// block named Block, replacing stone only.
+type OreVein struct {
+	Block    string
+	MinY     int
+	MaxY     int
+	PerChunk int
+	Size     int
+}
+
+// DefaultOres are the ore veins placed by the simplex generator unless
+// they are replaced with SetOres.
+var DefaultOres = []OreVein{
+	{Block: "coal_ore", MinY: 5, MaxY: 128, PerChunk: 12, Size: 12},
+	{Block: "iron_ore", MinY: 5, MaxY: 64, PerChunk: 8, Size: 8},
+	{Block: "gold_ore", MinY: 1, MaxY: 32, PerChunk: 2, Size: 6},
+}
+
+// carveCaves removes stone and dirt along tunnels following the zero bands
+// of two 3D noise fields. heights holds the terrain height of each column
+// of the chunk, indexed by z * CHUNK_SIZE + x.
+func (g *GeneratorSimplex) carveCaves(p Position, w BlockAccess, heights *[CHUNK_SIZE * CHUNK_SIZE]int) {
+	minY := p.Y << CHUNK_SHIFT
+	for z := 0; z < CHUNK_SIZE; z++ {
+		bz := p.Z<<CHUNK_SHIFT + z
+		for x := 0; x < CHUNK_SIZE; x++ {
+			bx := p.X<<CHUNK_SHIFT + x
+			maxY := heights[z*CHUNK_SIZE+x] - CAVE_MIN_DEPTH
+			for y := minY; y < minY+CHUNK_SIZE && y <= maxY; y++ {
+				if y < 1 {
+					continue
+				}
+				fx := float64(bx) * CAVE_FREQUENCY
+				fy := float64(y) * CAVE_FREQUENCY * 1.5
+				fz := float64(bz) * CAVE_FREQUENCY
+				n1 := simplexnoise.Noise3(fx, fy, fz+g.caveOffsets[0])
+				if math.Abs(n1) >= CAVE_THRESHOLD {
+					continue
+				}
+				n2 := simplexnoise.Noise3(fx, fy, fz+g.caveOffsets[1])
+				if math.Abs(n2) < CAVE_THRESHOLD {
+					w.SetBlock(bx, y, bz, nil)
+				}
+			}
+		}
+	}
+}
+
+// placeOres scatters the generator's ore veins over the stone of a chunk.
+// Veins are random walks confined to the chunk and to their range of
+// heights, seeded from the world seed and the chunk's position.
+func (g *GeneratorSimplex) placeOres(p Position, w BlockAccess) {
+	minY := p.Y << CHUNK_SHIFT
+	for i, ore := range g.ores {
+		block := g.oreBlocks[i]
+		if block == nil || ore.MaxY < minY || ore.MinY >= minY+CHUNK_SIZE {
+			continue
+		}
+		rand := chunkRand(g.seed, "ore/"+ore.Block, p)
+		for v := 0; v < ore.PerChunk; v++ {
+			x := p.X<<CHUNK_SHIFT + rand.Intn(CHUNK_SIZE)
+			y := minY + rand.Intn(CHUNK_SIZE)
+			z := p.Z<<CHUNK_SHIFT + rand.Intn(CHUNK_SIZE)
+			if y < ore.MinY || y > ore.MaxY {
+				continue
+			}
+			for n := 0; n < ore.Size; n++ {
+				if b := w.GetBlock(x, y, z); b != nil && b == g.stone && y >= ore.MinY && y <= ore.MaxY {
+					w.SetBlock(x, y, z, block.New())
+				}
+				switch rand.Intn(6) {
+				case 0:
+					x--
+				case 1:
+					x++
+				case 2:
+					y--
+				case 3:
+					y++
+				case 4:
+					z--
+				case 5:
+					z++
+				}
+			}
+		}
+	}
+}
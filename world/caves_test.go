@@ -0,0 +1,119 @@
+package world
+
+import (
+	"testing"
+)
+
+// generateChunk runs g over the chunk at p on its own.
+func generateChunk(g Generator, p Position) *Chunk {
+	c := &Chunk{}
+	g.GenerateChunk(p, &chunkAccess{c, p})
+	return c
+}
+
+// forChunk calls f with every position of the chunk at p and its block.
+func forChunk(c *Chunk, p Position, f func(x int, y int, z int, b Block)) {
+	for i := 0; i < CHUNK_VOLUME; i++ {
+		x, y, z := p.X<<CHUNK_SHIFT+i&CHUNK_MASK, p.Y<<CHUNK_SHIFT+i>>(2*CHUNK_SHIFT), p.Z<<CHUNK_SHIFT+(i>>CHUNK_SHIFT)&CHUNK_MASK
+		f(x, y, z, c.get(i))
+	}
+}
+
+func TestOreDepth(t *testing.T) {
+	br := newTestRegistry(testBlockNames)
+	g := NewGeneratorSimplex(br, 5)
+	ores := []OreVein{
+		{Block: "gold_ore", MinY: 20, MaxY: 27, PerChunk: 40, Size: 10},
+		{Block: "iron_ore", MinY: 0, MaxY: 3, PerChunk: 40, Size: 10},
+	}
+	g.SetOres(br, ores)
+	// the generator keeps its own copy
+	ores[0].MaxY = 100
+
+	counts := make(map[string]int)
+	for _, p := range []Position{{0, 0, 0}, {0, 1, 0}, {-3, 1, 2}, {2, 2, -5}} {
+		forChunk(generateChunk(g, p), p, func(x int, y int, z int, b Block) {
+			if b == nil {
+				return
+			}
+			switch b.Name() {
+			case "gold_ore":
+				if y < 20 || y > 27 {
+					t.Errorf("gold at y = %d, outside of 20 to 27", y)
+				}
+			case "iron_ore":
+				if y > 3 {
+					t.Errorf("iron at y = %d, above 3", y)
+				}
+			case "coal_ore":
+				t.Errorf("coal at y = %d, which was left out", y)
+			default:
+				return
+			}
+			counts[b.Name()]++
+		})
+	}
+	if counts["gold_ore"] == 0 || counts["iron_ore"] == 0 {
+		t.Errorf("ores placed: %v", counts)
+	}
+}
+
+func TestDefaultOresUnchanged(t *testing.T) {
+	want := append([]OreVein(nil), DefaultOres...)
+	br := newTestRegistry(testBlockNames)
+	g := NewGeneratorSimplex(br, 5)
+	g.ores[0].MaxY = 0
+	for i := range want {
+		if DefaultOres[i] != want[i] {
+			t.Errorf("changing a generator's ores changed DefaultOres[%d] to %+v", i, DefaultOres[i])
+		}
+	}
+}
+
+func TestCaves(t *testing.T) {
+	br := newTestRegistry(testBlockNames)
+	for _, seed := range []int64{1, 7, 1234} {
+		g := NewGeneratorSimplex(br, seed)
+		chunks := make(map[Position]*Chunk)
+		get := func(x int, y int, z int) Block {
+			p := chunkPos(x, y, z)
+			c, ok := chunks[p]
+			if !ok {
+				c = generateChunk(g, p)
+				chunks[p] = c
+			}
+			return c.get(chunkIndex(x, y, z))
+		}
+
+		caves := 0
+		for x := -32; x < 32; x++ {
+			for z := -32; z < 32; z++ {
+				height := g.SurfaceHeight(x, z)
+				if get(x, -1, z) != nil {
+					t.Fatalf("seed %d: block generated below y = 0", seed)
+				}
+				// caves stop above the bottom of the world
+				if get(x, 0, z) == nil {
+					t.Errorf("seed %d: cave at %d, 0, %d", seed, x, z)
+				}
+				// and below the surface
+				for y := height - CAVE_MIN_DEPTH + 1; y <= height; y++ {
+					if get(x, y, z) == nil {
+						t.Errorf("seed %d: cave at %d, %d, %d, just below the surface at %d", seed, x, y, z, height)
+					}
+				}
+				if get(x, height+1, z) != nil {
+					t.Errorf("seed %d: terrain above the surface of %d, %d", seed, x, z)
+				}
+				for y := 1; y <= height-CAVE_MIN_DEPTH; y++ {
+					if get(x, y, z) == nil {
+						caves++
+					}
+				}
+			}
+		}
+		if caves == 0 {
+			t.Errorf("seed %d: no caves", seed)
+		}
+	}
+}
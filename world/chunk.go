@@ -0,0 +1,297 @@
+package world
+
+import (
+	"sync"
+)
+
+const (
+	CHUNK_SIZE   = 16
+	CHUNK_SHIFT  = 4
+	CHUNK_MASK   = CHUNK_SIZE - 1
+	CHUNK_VOLUME = CHUNK_SIZE * CHUNK_SIZE * CHUNK_SIZE
+)
+
+// Chunk is a 16x16x16 section of the world. Blocks are stored as indices
+// into a chunk-local palette; a chunk consisting only of air does not hold
+// any block storage. Likewise, an evenly lit chunk only holds its light
+// level in lightFill.
+type Chunk struct {
+	palette   []Block
+	indices   PackedArray
+	count     int
+	light     []uint8
+	lightFill uint8
+	lit       bool
+}
+
+// WorldChunked is an unbounded world made out of chunks, allocated on demand.
+//
+// A WorldChunked is safe for concurrent use: its chunks, their blocks and
+// light are guarded by a single read-write lock. Render listeners are
+// notified once the lock is released, from the goroutine which made the
+// change, so they may read the world. Readers wanting a consistent view of
+// a chunk, such as the meshers, should take a Snapshot of it. Blocks next
+// to a block set with SetBlock are told about it after the render
+// listeners, as in NeighborListener.
+//
+// Once it has a save directory, a WorldChunked can unload regions of
+// chunks with UnloadFar. They are loaded back from the directory when one
+// of their chunks is next needed.
+type WorldChunked struct {
+	lock            *sync.RWMutex
+	chunks          map[Position]*Chunk
+	blockReg        BlockRegistry
+	generator       Generator
+	pending         map[Position][]pendingWrite
+	renderListeners []RenderListener
+	// events holds the notifications for the render listeners made while
+	// the world was locked
+	events    []worldEvent
+	neighbors *neighborQueue
+	// dir is the directory regions are unloaded into; dirty holds the
+	// regions changed since they were last saved there, and stored the
+	// regions saved there which are not loaded
+	dir     string
+	dirty   map[Position]bool
+	stored  map[Position]bool
+	loadErr error
+}
+
+// worldEvent is a notification for the render listeners: a block change
+// at pos, or the load of the chunk at pos.
+type worldEvent struct {
+	pos  Position
+	load bool
+}
+
+func chunkPos(x int, y int, z int) Position {
+	return Position{x >> CHUNK_SHIFT, y >> CHUNK_SHIFT, z >> CHUNK_SHIFT}
+}
+
+func chunkIndex(x int, y int, z int) int {
+	return ((y&CHUNK_MASK)*CHUNK_SIZE+(z&CHUNK_MASK))*CHUNK_SIZE + (x & CHUNK_MASK)
+}
+
+func (c *Chunk) IsEmpty() bool {
+	return c.count == 0
+}
+
+func (c *Chunk) get(i int) Block {
+	if c.palette == nil {
+		return nil
+	}
+	return c.palette[c.indices.Get(i)]
+}
+
+func (c *Chunk) set(i int, block Block) {
+	if c.palette == nil {
+		if block == nil {
+			return
+		}
+		c.palette = []Block{nil}
+		c.indices = NewPackedArray(1, CHUNK_VOLUME)
+	}
+	old := c.palette[c.indices.Get(i)]
+	if old == nil && block != nil {
+		c.count++
+	} else if old != nil && block == nil {
+		c.count--
+	}
+	if block == nil {
+		c.indices.Set(i, 0)
+	} else {
+		c.indices.Set(i, c.paletteIndex(block))
+	}
+	if c.count == 0 {
+		c.palette = nil
+		c.indices = PackedArray{}
+	}
+}
+
+func NewWorldChunked(blockReg BlockRegistry, generator Generator) WorldChunked {
+	return WorldChunked{
+		lock:      &sync.RWMutex{},
+		chunks:    make(map[Position]*Chunk, 1024),
+		blockReg:  blockReg,
+		generator: generator,
+		pending:   make(map[Position][]pendingWrite),
+		neighbors: newNeighborQueue(),
+		dirty:     make(map[Position]bool),
+		stored:    make(map[Position]bool),
+	}
+}
+
+// SetCascadeLimit sets how many times in a row block changes made by
+// NeighborListeners may set off further ones. Changes beyond the limit
+// still happen, but their neighbors aren't told about them.
+func (w *WorldChunked) SetCascadeLimit(depth int) {
+	w.neighbors.setLimit(depth)
+}
+
+func (w *WorldChunked) attachTicks(t TickAccess) {
+	w.neighbors.setTicks(t)
+}
+
+// unlock releases the write lock, then tells the render listeners about
+// the changes made while it was held.
+func (w *WorldChunked) unlock() {
+	events, listeners := w.events, w.renderListeners
+	w.events = nil
+	w.lock.Unlock()
+	for _, e := range events {
+		for _, listener := range listeners {
+			if listener == nil {
+				continue
+			}
+			if e.load {
+				listener.OnChunkLoad(e.pos)
+			} else {
+				listener.OnRenderUpdate(e.pos.X, e.pos.Y, e.pos.Z)
+			}
+		}
+	}
+}
+
+// LoadChunk makes the chunk at the given chunk position resident, loading
+// it back if it was unloaded, or running the world's generator and
+// decorators on it if it was never loaded.
+func (w *WorldChunked) LoadChunk(p Position) *Chunk {
+	w.lock.Lock()
+	defer w.unlock()
+	return w.loadChunk(p)
+}
+
+func (w *WorldChunked) loadChunk(p Position) *Chunk {
+	c, ok := w.chunks[p]
+	if !ok && w.stored[regionPos(p)] {
+		if !w.restoreRegion(regionPos(p)) {
+			// rather than generate the chunk anew, which would overwrite
+			// the saved one once unloaded, hand out an unloaded one
+			return &Chunk{}
+		}
+		c, ok = w.chunks[p]
+	}
+	if !ok {
+		c = &Chunk{}
+		w.dirty[regionPos(p)] = true
+		if w.generator != nil {
+			w.generator.GenerateChunk(p, &chunkAccess{c, p})
+		}
+		w.chunks[p] = c
+		if w.generator != nil {
+			w.decorate(p)
+		}
+		w.applyPending(p, c)
+		w.lightChunk(p)
+		w.events = append(w.events, worldEvent{pos: p, load: true})
+	}
+	return c
+}
+
+// LoadAround loads up to budget missing chunks within radius chunks of the
+// given block position, as in InViewDistance, nearest first. It returns the
+// number of chunks loaded.
+func (w *WorldChunked) LoadAround(x int, y int, z int, radius int, budget int) int {
+	center := chunkPos(x, y, z)
+	loaded := 0
+	for _, off := range ViewOffsets(radius) {
+		if loaded >= budget {
+			break
+		}
+		p := Position{center.X + off.X, center.Y + off.Y, center.Z + off.Z}
+		w.lock.RLock()
+		_, ok := w.chunks[p]
+		w.lock.RUnlock()
+		if !ok {
+			w.LoadChunk(p)
+			loaded++
+		}
+	}
+	return loaded
+}
+
+// GetChunk returns the chunk at the given chunk position, or nil. The
+// chunk must not be used while the world may be written to.
+func (w *WorldChunked) GetChunk(p Position) *Chunk {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.chunks[p]
+}
+
+// PopulatedChunks returns the positions of the loaded chunks holding any
+// blocks.
+func (w *WorldChunked) PopulatedChunks() []Position {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	chunks := make([]Position, 0, len(w.chunks))
+	for p, c := range w.chunks {
+		if !c.IsEmpty() {
+			chunks = append(chunks, p)
+		}
+	}
+	return chunks
+}
+
+func (w *WorldChunked) IsLoaded(x int, y int, z int) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	_, ok := w.chunks[chunkPos(x, y, z)]
+	return ok
+}
+
+func (w *WorldChunked) IsValid(x int, y int, z int) bool {
+	return true
+}
+
+func (w *WorldChunked) GetBlock(x int, y int, z int) Block {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.getBlock(x, y, z)
+}
+
+func (w *WorldChunked) getBlock(x int, y int, z int) Block {
+	if c, ok := w.chunks[chunkPos(x, y, z)]; ok {
+		return c.get(chunkIndex(x, y, z))
+	} else {
+		return nil
+	}
+}
+
+// GetBiome returns the biome of the given column, or nil if the world's
+// generator does not place biomes.
+func (w *WorldChunked) GetBiome(x int, z int) *Biome {
+	if bs, ok := w.generator.(BiomeSource); ok {
+		return bs.GetBiome(x, z)
+	}
+	return nil
+}
+
+func (w *WorldChunked) RegisterRenderListener(r RenderListener) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.renderListeners = append(w.renderListeners, r)
+}
+
+func (w *WorldChunked) SetBlock(x int, y int, z int, block Block) {
+	w.lock.Lock()
+	w.setBlock(x, y, z, block)
+	w.unlock()
+	w.neighbors.changed(w, Position{x, y, z})
+}
+
+func (w *WorldChunked) setBlock(x int, y int, z int, block Block) {
+	c, ok := w.chunks[chunkPos(x, y, z)]
+	if !ok {
+		// air needs no chunk, unless one was unloaded there
+		if block == nil && !w.stored[regionPos(chunkPos(x, y, z))] {
+			return
+		}
+		c = w.loadChunk(chunkPos(x, y, z))
+	}
+	c.set(chunkIndex(x, y, z), block)
+	w.dirty[regionPos(chunkPos(x, y, z))] = true
+	if c.lit {
+		w.updateLight(x, y, z)
+	}
+	w.events = append(w.events, worldEvent{pos: Position{x, y, z}})
+}
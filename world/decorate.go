@@ -0,0 +1,239 @@
+package world
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// Decorator places features, such as trees or structures, on top of the
+// terrain of a freshly generated chunk. Decorators may write outside of the
+// chunk they are decorating; writes into chunks which have not been
+// generated yet are deferred until they are. Either way, a decoration only
+// replaces the block already there as in decorationReplaces, and never
+// writes air, so that the decorated world doesn't depend on the order its
+// chunks are loaded in. For the same reason, reads outside of the chunk
+// being decorated return nil.
+type Decorator interface {
+	Decorate(p Position, w DecorationAccess, rand *rand.Rand)
+}
+
+// DecorationAccess is the view of the world handed to decorators.
+type DecorationAccess interface {
+	BlockAccess
+	// SurfaceHeight returns the height of the topmost block of a column,
+	// as generated.
+	SurfaceHeight(x int, z int) int
+}
+
+// DecoratingGenerator is implemented by generators which decorate their
+// chunks after generating them. SurfaceHeight returns the height of the
+// topmost block of a column, without generating it.
+type DecoratingGenerator interface {
+	Generator
+	Decorators() []Decorator
+	SurfaceHeight(x int, z int) int
+}
+
+type pendingWrite struct {
+	index int
+	block Block
+}
+
+// decorationReplaces reports whether a decoration writing b replaces the
+// block old. Air gives way to anything, blocks which aren't opaque, such as
+// leaves, to opaque ones, and blocks alike in that to the one whose name,
+// then state, sorts last. As this is a strict order, the blocks left behind
+// don't depend on the order of the writes.
+func decorationReplaces(old Block, b Block) bool {
+	switch {
+	case b == nil:
+		return false
+	case old == nil:
+		return true
+	case IsOpaque(old) != IsOpaque(b):
+		return IsOpaque(b)
+	case old.Name() != b.Name():
+		return b.Name() > old.Name()
+	}
+	return b.State() > old.State()
+}
+
+// decorationAccess is the DecorationAccess for the chunk p: it writes
+// directly into resident chunks and defers writes into other chunks.
+type decorationAccess struct {
+	w *WorldChunked
+	g DecoratingGenerator
+	p Position
+}
+
+func (a *decorationAccess) GetBlock(x int, y int, z int) Block {
+	if chunkPos(x, y, z) != a.p {
+		return nil
+	}
+	return a.w.getBlock(x, y, z)
+}
+
+func (a *decorationAccess) SetBlock(x int, y int, z int, block Block) {
+	p := chunkPos(x, y, z)
+	if a.w.stored[regionPos(p)] && !a.w.restoreRegion(regionPos(p)) {
+		return
+	}
+	if c, ok := a.w.chunks[p]; ok {
+		if decorationReplaces(c.get(chunkIndex(x, y, z)), block) {
+			a.w.setBlock(x, y, z, block)
+		}
+	} else if block != nil {
+		a.w.pending[p] = append(a.w.pending[p], pendingWrite{chunkIndex(x, y, z), block})
+		a.w.dirty[regionPos(p)] = true
+	}
+}
+
+func (a *decorationAccess) SurfaceHeight(x int, z int) int {
+	return a.g.SurfaceHeight(x, z)
+}
+
+// applyPending performs the deferred decoration writes into a newly
+// generated chunk.
+func (w *WorldChunked) applyPending(p Position, c *Chunk) {
+	for _, pw := range w.pending[p] {
+		if decorationReplaces(c.get(pw.index), pw.block) {
+			c.set(pw.index, pw.block)
+		}
+	}
+	delete(w.pending, p)
+}
+
+// decorate runs the generator's decorators over the newly generated chunk
+// p. It has to happen before the writes deferred into p are applied, so
+// that the decorators only see the chunk's own terrain.
+func (w *WorldChunked) decorate(p Position) {
+	dg, ok := w.generator.(DecoratingGenerator)
+	if !ok {
+		return
+	}
+	a := &decorationAccess{w, dg, p}
+	for i, d := range dg.Decorators() {
+		d.Decorate(p, a, chunkRand(w.generator.Seed(), "decorate/"+strconv.Itoa(i), p))
+	}
+}
+
+// findSurface returns the height and block of the terrain surface of a
+// column, or false if it lies outside of chunk p or has been covered by
+// an earlier decoration.
+func findSurface(w DecorationAccess, p Position, x int, z int) (int, Block, bool) {
+	y := w.SurfaceHeight(x, z)
+	if chunkPos(x, y, z) != p {
+		return 0, nil, false
+	}
+	b := w.GetBlock(x, y, z)
+	if b == nil || w.GetBlock(x, y+1, z) != nil {
+		return 0, nil, false
+	}
+	return y, b, true
+}
+
+// DecoratorTrees grows up to perChunk trees on top of the given ground
+// block.
+type DecoratorTrees struct {
+	ground   Block
+	log      Block
+	leaves   Block
+	perChunk int
+}
+
+func (d *DecoratorTrees) Decorate(p Position, w DecorationAccess, rand *rand.Rand) {
+	if d.ground == nil || d.log == nil || d.leaves == nil {
+		return
+	}
+	for i := 0; i < d.perChunk; i++ {
+		x := p.X<<CHUNK_SHIFT + rand.Intn(CHUNK_SIZE)
+		z := p.Z<<CHUNK_SHIFT + rand.Intn(CHUNK_SIZE)
+		height := 4 + rand.Intn(3)
+		y, ground, ok := findSurface(w, p, x, z)
+		if !ok || ground != d.ground {
+			continue
+		}
+		top := y + height
+		for ly := top - 2; ly <= top+1; ly++ {
+			radius := 2
+			if ly > top-1 {
+				radius = 1
+			}
+			for lz := z - radius; lz <= z+radius; lz++ {
+				for lx := x - radius; lx <= x+radius; lx++ {
+					corner := (lx == x-radius || lx == x+radius) && (lz == z-radius || lz == z+radius)
+					if corner && (ly == top+1 || rand.Intn(2) == 0) {
+						continue
+					}
+					w.SetBlock(lx, ly, lz, d.leaves.New())
+				}
+			}
+		}
+		for ly := y + 1; ly <= top; ly++ {
+			w.SetBlock(x, ly, z, d.log.New())
+		}
+	}
+}
+
+// Prefab is a small structure made out of layers of blocks, from the bottom
+// up. Each layer is a list of rows along the z axis, each row a string of
+// characters along the x axis looked up in blocks; characters missing from
+// blocks leave the existing block in place.
+type Prefab struct {
+	layers [][]string
+	blocks map[rune]string
+}
+
+var PrefabWell = Prefab{
+	layers: [][]string{
+		{"sssss", "sssss", "sssss", "sssss", "sssss"},
+		{"sssss", "s   s", "s   s", "s   s", "sssss"},
+		{"s   s", "     ", "     ", "     ", "s   s"},
+		{"s   s", "     ", "     ", "     ", "s   s"},
+		{"ttttt", "ttttt", "ttttt", "ttttt", "ttttt"},
+	},
+	blocks: map[rune]string{'s': "sandstone", 't': "stone_slab"},
+}
+
+// DecoratorPrefab places a prefab on top of the given ground block, in one
+// out of every rarity chunks.
+type DecoratorPrefab struct {
+	prefab Prefab
+	blocks map[rune]Block
+	ground Block
+	rarity int
+}
+
+func NewDecoratorPrefab(blockReg BlockRegistry, prefab Prefab, ground string, rarity int) *DecoratorPrefab {
+	d := &DecoratorPrefab{
+		prefab: prefab,
+		blocks: make(map[rune]Block, len(prefab.blocks)),
+		ground: blockReg.ByName(ground),
+		rarity: rarity,
+	}
+	for r, name := range prefab.blocks {
+		d.blocks[r] = blockReg.ByName(name)
+	}
+	return d
+}
+
+func (d *DecoratorPrefab) Decorate(p Position, w DecorationAccess, rand *rand.Rand) {
+	if d.ground == nil || rand.Intn(d.rarity) != 0 {
+		return
+	}
+	x := p.X<<CHUNK_SHIFT + rand.Intn(CHUNK_SIZE)
+	z := p.Z<<CHUNK_SHIFT + rand.Intn(CHUNK_SIZE)
+	y, ground, ok := findSurface(w, p, x, z)
+	if !ok || ground != d.ground {
+		return
+	}
+	for ly, layer := range d.prefab.layers {
+		for lz, row := range layer {
+			for lx, c := range row {
+				if block, ok := d.blocks[c]; ok {
+					w.SetBlock(x+lx, y+ly, z+lz, block)
+				}
+			}
+		}
+	}
+}
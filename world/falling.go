@@ -0,0 +1,57 @@
+package world
+
+// FALL_DELAY is the number of ticks a falling block waits, once its support
+// is gone, before falling.
+const FALL_DELAY = 2
+
+// BlockFalling is a full cube block, such as sand, which falls once the
+// block below it is removed. It falls through air and fluids, but not into
+// unloaded chunks.
+type BlockFalling struct {
+	*BlockSimple
+}
+
+func NewBlockFalling(name string, textures [6]string) *BlockFalling {
+	return &BlockFalling{NewBlockSimple(name, textures)}
+}
+
+// supported reports whether the block below the given position holds up a
+// falling block.
+func supported(w World, x int, y int, z int) bool {
+	if !w.IsLoaded(x, y-1, z) {
+		return true
+	}
+	switch w.GetBlock(x, y-1, z).(type) {
+	case nil, *BlockFluid:
+		return false
+	}
+	return true
+}
+
+func (b *BlockFalling) OnNeighborChange(w TickAccess, x int, y int, z int, from Direction) {
+	if from == DOWN && !supported(w, x, y, z) {
+		w.ScheduleTick(x, y, z, FALL_DELAY, 0)
+	}
+}
+
+// ScheduledTick drops the block onto the first supporting block below it,
+// if it is still unsupported.
+func (b *BlockFalling) ScheduledTick(w TickAccess, x int, y int, z int) {
+	ty := y
+	for !supported(w, x, ty, z) {
+		ty--
+	}
+	if ty == y {
+		return
+	}
+	w.SetBlock(x, y, z, nil)
+	w.SetBlock(x, ty, z, b)
+}
+
+func (b *BlockFalling) New() Block {
+	return b
+}
+
+func (b *BlockFalling) WithState(s BlockState) Block {
+	return b
+}
@@ -0,0 +1,265 @@
+package world
+
+var (
+	PropertyLevel   = Property{"level", []string{"0", "1", "2", "3", "4", "5", "6", "7"}}
+	PropertyFalling = Property{"falling", []string{"false", "true"}}
+)
+
+var fluidProperties = []Property{PropertyLevel, PropertyFalling}
+
+// BlockFluid is a fluid such as water or lava. Level 0 is a source block;
+// fluid flowing out of it sideways rises a level with every block, up to
+// the fluid's reach. Falling fluid has fluid above it and fills its whole
+// position.
+type BlockFluid struct {
+	name    string
+	texture string
+	layer   RenderLayer
+	light   uint8
+	reach   int
+	delay   int
+	state   BlockState
+	states  []*BlockFluid
+	models  modelCache
+}
+
+// NewBlockFluid creates the source block of a fluid drawn in the given
+// render layer and giving off light of the given level. The fluid spreads
+// up to reach blocks sideways from its sources, one block every delay
+// ticks.
+func NewBlockFluid(name string, texture string, layer RenderLayer, light uint8, reach int, delay int) *BlockFluid {
+	if reach >= len(PropertyLevel.values) {
+		reach = len(PropertyLevel.values) - 1
+	}
+	states := make([]*BlockFluid, StateCount(fluidProperties))
+	for i := range states {
+		states[i] = &BlockFluid{name: name, texture: texture, layer: layer, light: light, reach: reach, delay: delay, state: BlockState(i), states: states}
+	}
+	return states[0]
+}
+
+// Level returns how many blocks the fluid has flowed sideways from its
+// source; 0 for the source itself.
+func (b *BlockFluid) Level() int {
+	return PropertyLevel.index(b.state.Get(fluidProperties, "level"))
+}
+
+func (b *BlockFluid) Falling() bool {
+	return b.state.Get(fluidProperties, "falling") == "true"
+}
+
+func (b *BlockFluid) IsSource() bool {
+	return b.Level() == 0 && !b.Falling()
+}
+
+// Height returns the height of the fluid's surface within its position.
+func (b *BlockFluid) Height() float32 {
+	if b.Falling() {
+		return 1
+	}
+	return float32(len(PropertyLevel.values)-b.Level()) / float32(len(PropertyLevel.values)+1)
+}
+
+// Delay returns the number of ticks the fluid takes to flow one block.
+func (b *BlockFluid) Delay() int {
+	return b.delay
+}
+
+// flowing returns the state of the fluid at the given level.
+func (b *BlockFluid) flowing(level int, falling bool) *BlockFluid {
+	s := b.states[0].state.With(fluidProperties, "level", PropertyLevel.values[level])
+	if falling {
+		s = s.With(fluidProperties, "falling", "true")
+	}
+	return b.states[s]
+}
+
+// same reports whether blk is a state of the fluid b.
+func (b *BlockFluid) same(blk Block) bool {
+	f, ok := blk.(*BlockFluid)
+	return ok && f.name == b.name
+}
+
+// spreadLevel returns the level of the fluid as seen by the fluid it
+// feeds sideways. Sources and falling fluid feed the level below theirs.
+func (b *BlockFluid) spreadLevel() int {
+	if b.Falling() {
+		return 0
+	}
+	return b.Level()
+}
+
+func (b *BlockFluid) Name() string {
+	return b.name
+}
+
+func (b *BlockFluid) GetModel(a TextureAtlas) Model {
+	return b.models.get(a, func() Model {
+		var ta [6]Texture
+		for i := 0; i < 6; i++ {
+			ta[i] = a.Texture(b.texture)
+		}
+		bb := b.GetBoundingBox()
+		return NewCubeModel(ta, bb.Min, bb.Max)
+	})
+}
+
+func (b *BlockFluid) GetBoundingBox() BoundingBox {
+	return BoundingBox{Vec3{0, 0, 0}, Vec3{1, b.Height(), 1}}
+}
+
+func (b *BlockFluid) IsSideSolid(d Direction) bool {
+	return false
+}
+
+func (b *BlockFluid) LightEmission() uint8 {
+	return b.light
+}
+
+func (b *BlockFluid) RenderLayer() RenderLayer {
+	return b.layer
+}
+
+func (b *BlockFluid) New() Block {
+	return b
+}
+
+func (b *BlockFluid) Properties() []Property {
+	return fluidProperties
+}
+
+func (b *BlockFluid) State() BlockState {
+	return b.state
+}
+
+func (b *BlockFluid) WithState(s BlockState) Block {
+	if int(s) >= len(b.states) {
+		return b
+	}
+	return b.states[s]
+}
+
+// OnNeighborChange has the fluid flow once its delay has passed, after it
+// or a block next to it changed. Fluids only flow into loaded chunks.
+func (b *BlockFluid) OnNeighborChange(w TickAccess, x int, y int, z int, from Direction) {
+	w.ScheduleTick(x, y, z, b.delay, 0)
+}
+
+// Fluids resumes the flow of the fluids in the chunks of a world as they
+// are loaded, as scheduled ticks aren't saved. It listens to the loads as
+// a RenderListener and schedules the ticks with a Scheduler.
+type Fluids struct {
+	s *Scheduler
+}
+
+// NewFluids creates a Fluids for the world of s, resuming the flow of the
+// fluids in the chunks already loaded.
+func NewFluids(s *Scheduler) *Fluids {
+	f := &Fluids{s: s}
+	if cl, ok := s.World.(ChunkLister); ok {
+		for _, p := range cl.PopulatedChunks() {
+			f.OnChunkLoad(p)
+		}
+	}
+	return f
+}
+
+func (f *Fluids) OnRenderUpdate(x int, y int, z int) {
+}
+
+// OnChunkLoad schedules the flowing fluids of the chunk at p, along with
+// its sources which still have room to spread.
+func (f *Fluids) OnChunkLoad(p Position) {
+	for i := 0; i < CHUNK_VOLUME; i++ {
+		x, y, z := p.X<<CHUNK_SHIFT+i&CHUNK_MASK, p.Y<<CHUNK_SHIFT+i>>(2*CHUNK_SHIFT), p.Z<<CHUNK_SHIFT+(i>>CHUNK_SHIFT)&CHUNK_MASK
+		b, ok := f.s.GetBlock(x, y, z).(*BlockFluid)
+		if !ok || (b.IsSource() && !b.canSpread(f.s, x, y, z)) {
+			continue
+		}
+		f.s.ScheduleTick(x, y, z, b.delay, 0)
+	}
+}
+
+// canSpread reports whether there is air below or beside the fluid b at
+// the given position.
+func (b *BlockFluid) canSpread(w World, x int, y int, z int) bool {
+	for d := DOWN; d <= FORWARD; d++ {
+		if d == UP {
+			continue
+		}
+		off := d.Offset()
+		if w.IsLoaded(x+off.X, y+off.Y, z+off.Z) && w.GetBlock(x+off.X, y+off.Y, z+off.Z) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduledTick makes the flowing fluid at the given position match the
+// fluid feeding it, or spreads it if it already does.
+func (b *BlockFluid) ScheduledTick(w TickAccess, x int, y int, z int) {
+	if !b.IsSource() {
+		if next := b.supply(w, x, y, z); next == nil {
+			w.SetBlock(x, y, z, nil)
+			return
+		} else if next != b {
+			w.SetBlock(x, y, z, next)
+			return
+		}
+	}
+	b.spread(w, x, y, z)
+}
+
+// supply returns the state the flowing fluid b at the given position is
+// fed into by the fluid above and beside it, or nil if it dries up.
+func (b *BlockFluid) supply(w World, x int, y int, z int) *BlockFluid {
+	if b.same(w.GetBlock(x, y+1, z)) {
+		return b.flowing(1, true)
+	}
+	level := b.reach + 1
+	for d := LEFT; d <= FORWARD; d++ {
+		off := d.Offset()
+		if n, ok := w.GetBlock(x+off.X, y, z+off.Z).(*BlockFluid); ok && b.same(n) && n.spreadLevel()+1 < level {
+			level = n.spreadLevel() + 1
+		}
+	}
+	if level > b.reach {
+		return nil
+	}
+	return b.flowing(level, false)
+}
+
+// spread lets the fluid b at the given position fall into the position
+// below it or, if it can't and for sources, flow sideways.
+func (b *BlockFluid) spread(w World, x int, y int, z int) {
+	if w.IsLoaded(x, y-1, z) {
+		below := w.GetBlock(x, y-1, z)
+		if below == nil || (b.same(below) && !below.(*BlockFluid).IsSource()) {
+			if fall := b.flowing(1, true); below != fall {
+				w.SetBlock(x, y-1, z, fall)
+			}
+			if !b.IsSource() {
+				return
+			}
+		}
+	}
+
+	level := b.spreadLevel() + 1
+	if level > b.reach {
+		return
+	}
+	next := b.flowing(level, false)
+	for d := LEFT; d <= FORWARD; d++ {
+		off := d.Offset()
+		nx, nz := x+off.X, z+off.Z
+		if !w.IsLoaded(nx, y, nz) {
+			continue
+		}
+		n := w.GetBlock(nx, y, nz)
+		if n == nil {
+			w.SetBlock(nx, y, nz, next)
+		} else if nf, ok := n.(*BlockFluid); ok && b.same(nf) && !nf.Falling() && nf.Level() > level {
+			w.SetBlock(nx, y, nz, next)
+		}
+	}
+}
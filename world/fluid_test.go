@@ -0,0 +1,170 @@
+package world
+
+import (
+	"testing"
+)
+
+// newFluidWorld returns a flat world with a stone floor at y = 0, and a
+// scheduler running its fluids without random ticks.
+func newFluidWorld(t *testing.T) (*WorldChunked, *Scheduler, *BlockFluid, Block) {
+	br := NewBlockRegistry()
+	stone := NewBlockSimple("stone", [6]string{})
+	water := NewBlockFluid("water", "water.png", LAYER_TRANSLUCENT, 0, 7, 5)
+	br.Register(stone)
+	br.Register(water)
+	g, err := NewGeneratorFlat(br, 1, "stone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWorldChunked(br, g)
+	s := NewScheduler(&w, 1)
+	s.RandomTicks = 0
+	w.RegisterRenderListener(NewFluids(s))
+	w.LoadAround(0, 0, 0, 2, 1000)
+	return &w, s, water, stone
+}
+
+// settle ticks s until no ticks are left pending.
+func settle(t *testing.T, s *Scheduler) {
+	for i := 0; i < 1000; i++ {
+		s.Tick()
+		if s.Pending() == 0 {
+			return
+		}
+	}
+	t.Fatal("fluid did not settle")
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// noFluid fails t if any fluid is left between the given corners.
+func noFluid(t *testing.T, w BlockAccess, x0, y0, z0, x1, y1, z1 int) {
+	for x := x0; x <= x1; x++ {
+		for y := y0; y <= y1; y++ {
+			for z := z0; z <= z1; z++ {
+				if b, ok := w.GetBlock(x, y, z).(*BlockFluid); ok {
+					t.Fatalf("fluid level %d left at %d, %d, %d", b.Level(), x, y, z)
+				}
+			}
+		}
+	}
+}
+
+func TestFluidSpread(t *testing.T) {
+	w, s, water, _ := newFluidWorld(t)
+	w.SetBlock(0, 1, 0, water)
+	settle(t, s)
+	for x := -10; x <= 10; x++ {
+		for z := -10; z <= 10; z++ {
+			b := w.GetBlock(x, 1, z)
+			d := abs(x) + abs(z)
+			if d > water.reach {
+				if b != nil {
+					t.Errorf("%d, %d: %s beyond the reach", x, z, b.Name())
+				}
+				continue
+			}
+			if fb, ok := b.(*BlockFluid); !ok || fb.Level() != d || fb.Falling() {
+				t.Errorf("%d, %d: %v, want level %d", x, z, b, d)
+			}
+		}
+	}
+
+	w.SetBlock(0, 1, 0, nil)
+	settle(t, s)
+	noFluid(t, w, -10, 1, -10, 10, 1, 10)
+}
+
+func TestFluidFall(t *testing.T) {
+	w, s, water, stone := newFluidWorld(t)
+	// a pillar with a source on top
+	for y := 1; y < 6; y++ {
+		w.SetBlock(5, y, 5, stone)
+	}
+	w.SetBlock(5, 6, 5, water)
+	settle(t, s)
+
+	// it flows off the pillar and falls to the floor
+	if b, ok := w.GetBlock(6, 6, 5).(*BlockFluid); !ok || b.Level() != 1 {
+		t.Errorf("beside the source: %v", w.GetBlock(6, 6, 5))
+	}
+	for y := 1; y < 6; y++ {
+		if b, ok := w.GetBlock(6, y, 5).(*BlockFluid); !ok || !b.Falling() {
+			t.Errorf("falling column at y = %d: %v", y, w.GetBlock(6, y, 5))
+		}
+	}
+	// falling fluid doesn't spread sideways on the way down
+	if b := w.GetBlock(7, 6, 5); b != nil {
+		t.Errorf("spread over the drop: %v", b)
+	}
+	// and spreads again where it lands
+	if b, ok := w.GetBlock(8, 1, 5).(*BlockFluid); !ok || b.Level() != 2 {
+		t.Errorf("landing: %v", w.GetBlock(8, 1, 5))
+	}
+
+	w.SetBlock(5, 6, 5, nil)
+	settle(t, s)
+	noFluid(t, w, -3, 1, -5, 15, 7, 15)
+}
+
+// TestFluidResume saves a world while its water is still spreading, and
+// checks that it spreads the same way once loaded again.
+func TestFluidResume(t *testing.T) {
+	w, s, water, _ := newFluidWorld(t)
+	w.SetBlock(0, 1, 0, water)
+	for i := 0; i < 3*water.delay; i++ {
+		s.Tick()
+	}
+	if s.Pending() == 0 {
+		t.Fatal("water settled before saving")
+	}
+
+	dir := t.TempDir()
+	if err := SaveWorld(w, dir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadWorld(dir, w.blockReg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls := NewScheduler(&loaded, 1)
+	ls.RandomTicks = 0
+	loaded.RegisterRenderListener(NewFluids(ls))
+	settle(t, ls)
+	settle(t, s)
+
+	for x := -10; x <= 10; x++ {
+		for y := 0; y <= 2; y++ {
+			for z := -10; z <= 10; z++ {
+				if a, b := w.GetBlock(x, y, z), loaded.GetBlock(x, y, z); !sameBlock(a, b) {
+					t.Errorf("%d, %d, %d: %v after loading, want %v", x, y, z, b, a)
+				}
+			}
+		}
+	}
+	if b, ok := loaded.GetBlock(0, 1, 7).(*BlockFluid); !ok || b.Level() != 7 {
+		t.Errorf("water didn't reach its end after loading: %v", loaded.GetBlock(0, 1, 7))
+	}
+}
+
+func TestFluidState(t *testing.T) {
+	water := NewBlockFluid("water", "water.png", LAYER_TRANSLUCENT, 0, 7, 5)
+	if !water.IsSource() || water.Level() != 0 || water.Height() != 8.0/9 {
+		t.Errorf("source: level %d, height %v", water.Level(), water.Height())
+	}
+	b := water.flowing(3, false)
+	if b.Level() != 3 || b.Falling() || b.IsSource() || b.Name() != "water" {
+		t.Errorf("flowing: level %d, falling %v", b.Level(), b.Falling())
+	}
+	if b.WithState(b.State()) != b {
+		t.Errorf("flowing state doesn't round trip")
+	}
+	if f := water.flowing(1, true); !f.Falling() || f.Height() != 1 {
+		t.Errorf("falling: height %v", f.Height())
+	}
+}
@@ -0,0 +1,71 @@
+package world
+
+// Plane is the plane of points p with N·p + D = 0. Points with N·p + D > 0
+// are in front of it.
+type Plane struct {
+	N Vec3
+	D float32
+}
+
+// Distance returns the signed distance of p from the plane, scaled by the
+// length of its normal.
+func (pl Plane) Distance(p Vec3) float32 {
+	return pl.N[0]*p[0] + pl.N[1]*p[1] + pl.N[2]*p[2] + pl.D
+}
+
+// Frustum is the volume visible through a camera, bounded by the left,
+// right, bottom, top, near and far planes, all facing inwards.
+type Frustum [6]Plane
+
+// NewFrustum returns the frustum of the combined projection and view
+// matrix m, which maps world to clip coordinates.
+func NewFrustum(m Mat4) Frustum {
+	row := func(r int) Vec4 {
+		return Vec4{m.At(r, 0), m.At(r, 1), m.At(r, 2), m.At(r, 3)}
+	}
+	plane := func(a Vec4, b Vec4, sign float32) Plane {
+		return Plane{
+			N: Vec3{a[0] + sign*b[0], a[1] + sign*b[1], a[2] + sign*b[2]},
+			D: a[3] + sign*b[3],
+		}
+	}
+	// a point is visible if -w <= x, y, z <= w in clip coordinates
+	w := row(3)
+	var f Frustum
+	for i := 0; i < 3; i++ {
+		f[i*2] = plane(w, row(i), 1)
+		f[i*2+1] = plane(w, row(i), -1)
+	}
+	return f
+}
+
+// ContainsPoint reports whether p is inside the frustum.
+func (f *Frustum) ContainsPoint(p Vec3) bool {
+	for _, pl := range f {
+		if pl.Distance(p) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsBox reports whether any part of b may be inside the frustum.
+// Boxes near the frustum's edges may be reported as intersecting it even
+// if they are outside.
+func (f *Frustum) IntersectsBox(b BoundingBox) bool {
+	for _, pl := range f {
+		// the corner of the box furthest in front of the plane
+		var p Vec3
+		for i := 0; i < 3; i++ {
+			if pl.N[i] >= 0 {
+				p[i] = b.Max[i]
+			} else {
+				p[i] = b.Min[i]
+			}
+		}
+		if pl.Distance(p) < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,285 @@
+package world
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/larspensjo/Go-simplex-noise/simplexnoise"
+)
+
+// Generator fills in the contents of newly loaded chunks. GenerateChunk
+// receives the chunk's position and a BlockAccess which only accepts
+// positions inside of that chunk.
+type Generator interface {
+	Named
+	Seed() int64
+	Options() string
+	GenerateChunk(p Position, w BlockAccess)
+}
+
+// NewGenerator creates a generator from its name, as returned by Name, and
+// its options string, as returned by Options.
+func NewGenerator(name string, seed int64, options string, blockReg BlockRegistry) (Generator, error) {
+	switch name {
+	case "simplex":
+		return NewGeneratorSimplex(blockReg, seed), nil
+	case "flat":
+		return NewGeneratorFlat(blockReg, seed, options)
+	case "void":
+		return &GeneratorVoid{seed: seed}, nil
+	default:
+		return nil, fmt.Errorf("unknown generator %q", name)
+	}
+}
+
+// chunkAccess is a BlockAccess limited to a single chunk, used to generate
+// chunks before they are added to the world.
+type chunkAccess struct {
+	c *Chunk
+	p Position
+}
+
+func (a *chunkAccess) GetBlock(x int, y int, z int) Block {
+	if chunkPos(x, y, z) != a.p {
+		return nil
+	}
+	return a.c.get(chunkIndex(x, y, z))
+}
+
+func (a *chunkAccess) SetBlock(x int, y int, z int, block Block) {
+	if chunkPos(x, y, z) == a.p {
+		a.c.set(chunkIndex(x, y, z), block)
+	}
+}
+
+// GeneratorSimplex builds a heightmap out of several octaves of simplex
+// noise, shaped and covered according to the biome of each column, then
+// carves caves and places ores below it. Nothing is generated below y = 0.
+type GeneratorSimplex struct {
+	seed           int64
+	offsets        [len(simplexFrequencies)]float64
+	climateOffsets [2]float64
+	caveOffsets    [2]float64
+	stone          Block
+	surfaces       [len(Biomes)][2]Block
+	snow           Block
+	ores           []OreVein
+	oreBlocks      []Block
+	decorators     []Decorator
+}
+
+var (
+	simplexFrequencies = [6]float64{0.004, 0.008, 0.016, 0.032, 0.064, 0.0128}
+	simplexAmplitudes  = [6]float64{32, 16, 8, 4, 2, 1}
+)
+
+func NewGeneratorSimplex(blockReg BlockRegistry, seed int64) *GeneratorSimplex {
+	g := &GeneratorSimplex{
+		seed:  seed,
+		stone: blockReg.ByName("stone"),
+		snow:  blockReg.ByName("snow"),
+	}
+	for i := range g.offsets {
+		g.offsets[i] = noiseOffset(seed, "heightmap/"+strconv.Itoa(i))
+	}
+	g.climateOffsets[0] = noiseOffset(seed, "temperature")
+	g.climateOffsets[1] = noiseOffset(seed, "humidity")
+	g.caveOffsets[0] = noiseOffset(seed, "caves/0")
+	g.caveOffsets[1] = noiseOffset(seed, "caves/1")
+	g.SetOres(blockReg, DefaultOres)
+	g.decorators = []Decorator{
+		&DecoratorTrees{
+			ground:   blockReg.ByName("grass"),
+			log:      blockReg.ByName("log"),
+			leaves:   blockReg.ByName("leaves"),
+			perChunk: 3,
+		},
+		NewDecoratorPrefab(blockReg, PrefabWell, "sand", 24),
+	}
+	for i, b := range Biomes {
+		g.surfaces[i][0] = blockReg.ByName(b.surface)
+		g.surfaces[i][1] = blockReg.ByName(b.subsurface)
+		if g.surfaces[i][0] == nil {
+			g.surfaces[i][0] = blockReg.ByName("grass")
+		}
+		if g.surfaces[i][1] == nil {
+			g.surfaces[i][1] = blockReg.ByName("dirt")
+		}
+	}
+	if g.snow == nil {
+		g.snow = g.surfaces[0][0]
+	}
+	return g
+}
+
+// SetOres replaces the ore veins placed by the generator with a copy of
+// ores.
+func (g *GeneratorSimplex) SetOres(blockReg BlockRegistry, ores []OreVein) {
+	g.ores = append([]OreVein(nil), ores...)
+	g.oreBlocks = make([]Block, len(ores))
+	for i, ore := range ores {
+		g.oreBlocks[i] = blockReg.ByName(ore.Block)
+	}
+}
+
+func (g *GeneratorSimplex) Decorators() []Decorator {
+	return g.decorators
+}
+
+// SurfaceHeight returns the terrain height of a column. Caves never reach
+// the surface, so this is the height of its topmost block.
+func (g *GeneratorSimplex) SurfaceHeight(x int, z int) int {
+	height, _ := g.column(x, z)
+	return height
+}
+
+func (g *GeneratorSimplex) Name() string {
+	return "simplex"
+}
+
+func (g *GeneratorSimplex) Seed() int64 {
+	return g.seed
+}
+
+func (g *GeneratorSimplex) Options() string {
+	return ""
+}
+
+// column returns the terrain height of a column, blended between the
+// biomes influencing it, and the index of its dominant biome.
+func (g *GeneratorSimplex) column(x int, z int) (int, int) {
+	var noise [len(simplexFrequencies)]float64
+	for i := 0; i < len(simplexFrequencies); i++ {
+		noise[i] = simplexnoise.Noise3(float64(x)*simplexFrequencies[i], float64(z)*simplexFrequencies[i], g.offsets[i]) * simplexAmplitudes[i]
+	}
+	weights := g.biomeWeights(x, z)
+	heightF := 0.0
+	dominant := 0
+	for bi, b := range Biomes {
+		if weights[bi] > weights[dominant] {
+			dominant = bi
+		}
+		h := 0.0
+		for i := range noise {
+			h += noise[i] * b.octaveWeights[i]
+		}
+		heightF += weights[bi] * (b.height + h*b.heightScale)
+	}
+	return int(heightF), dominant
+}
+
+func (g *GeneratorSimplex) GenerateChunk(p Position, w BlockAccess) {
+	if p.Y < 0 {
+		return
+	}
+	var heights [CHUNK_SIZE * CHUNK_SIZE]int
+	minY := p.Y << CHUNK_SHIFT
+	for z := p.Z << CHUNK_SHIFT; z < (p.Z+1)<<CHUNK_SHIFT; z++ {
+		for x := p.X << CHUNK_SHIFT; x < (p.X+1)<<CHUNK_SHIFT; x++ {
+			height, bi := g.column(x, z)
+			heights[(z&CHUNK_MASK)*CHUNK_SIZE+(x&CHUNK_MASK)] = height
+			surface := g.surfaces[bi][0]
+			if snowLine := Biomes[bi].snowLine; snowLine != 0 && height >= snowLine {
+				surface = g.snow
+			}
+			for y := minY; y < minY+CHUNK_SIZE && y <= height; y++ {
+				if y == height {
+					w.SetBlock(x, y, z, surface.New())
+				} else if y >= height-3 {
+					w.SetBlock(x, y, z, g.surfaces[bi][1].New())
+				} else {
+					w.SetBlock(x, y, z, g.stone.New())
+				}
+			}
+		}
+	}
+	g.carveCaves(p, w, &heights)
+	g.placeOres(p, w)
+}
+
+// GeneratorFlat stacks layers of blocks on top of y = 0. Its preset is a
+// comma-separated list of layers from the bottom up, each being a block name
+// optionally prefixed with a repeat count, such as "3*stone,2*dirt,grass".
+type GeneratorFlat struct {
+	seed   int64
+	preset string
+	layers []Block
+}
+
+const DEFAULT_FLAT_PRESET = "3*stone,2*dirt,grass"
+
+func NewGeneratorFlat(blockReg BlockRegistry, seed int64, preset string) (*GeneratorFlat, error) {
+	if preset == "" {
+		preset = DEFAULT_FLAT_PRESET
+	}
+	g := &GeneratorFlat{seed: seed, preset: preset}
+	for _, layer := range strings.Split(preset, ",") {
+		layer = strings.TrimSpace(layer)
+		count := 1
+		if i := strings.Index(layer, "*"); i >= 0 {
+			n, err := strconv.Atoi(layer[:i])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid layer count in %q", layer)
+			}
+			count = n
+			layer = layer[i+1:]
+		}
+		var block Block
+		if layer != "air" {
+			if block = blockReg.ByName(layer); block == nil {
+				return nil, fmt.Errorf("unknown block %q", layer)
+			}
+		}
+		for i := 0; i < count; i++ {
+			g.layers = append(g.layers, block)
+		}
+	}
+	return g, nil
+}
+
+func (g *GeneratorFlat) Name() string {
+	return "flat"
+}
+
+func (g *GeneratorFlat) Seed() int64 {
+	return g.seed
+}
+
+func (g *GeneratorFlat) Options() string {
+	return g.preset
+}
+
+func (g *GeneratorFlat) GenerateChunk(p Position, w BlockAccess) {
+	minY := p.Y << CHUNK_SHIFT
+	for y := minY; y < minY+CHUNK_SIZE; y++ {
+		if y < 0 || y >= len(g.layers) || g.layers[y] == nil {
+			continue
+		}
+		for z := p.Z << CHUNK_SHIFT; z < (p.Z+1)<<CHUNK_SHIFT; z++ {
+			for x := p.X << CHUNK_SHIFT; x < (p.X+1)<<CHUNK_SHIFT; x++ {
+				w.SetBlock(x, y, z, g.layers[y].New())
+			}
+		}
+	}
+}
+
+// GeneratorVoid generates nothing but air.
+type GeneratorVoid struct {
+	seed int64
+}
+
+func (g *GeneratorVoid) Name() string {
+	return "void"
+}
+
+func (g *GeneratorVoid) Seed() int64 {
+	return g.seed
+}
+
+func (g *GeneratorVoid) Options() string {
+	return ""
+}
+
+func (g *GeneratorVoid) GenerateChunk(p Position, w BlockAccess) {
+}
@@ -0,0 +1,52 @@
+package world
+
+import (
+	"math/rand"
+)
+
+// GRASS_LIGHT is the light grass needs above the dirt it spreads to.
+const GRASS_LIGHT = 9
+
+// BlockGrass is a full cube block which spreads to the dirt around it and
+// turns back into dirt when covered.
+type BlockGrass struct {
+	*BlockSimple
+	dirt Block
+}
+
+// NewBlockGrass creates grass which turns into dirt, and spreads to it.
+func NewBlockGrass(name string, textures [6]string, dirt Block) *BlockGrass {
+	return &BlockGrass{NewBlockSimple(name, textures), dirt}
+}
+
+// covered reports whether the block above grass at the given position
+// smothers it.
+func covered(w BlockAccess, x int, y int, z int) bool {
+	return IsSideSolid(w.GetBlock(x, y+1, z), DOWN)
+}
+
+// RandomTick turns covered grass into dirt. Otherwise, the grass spreads
+// to a random position up to one block away horizontally, three blocks
+// below and one above, if it holds dirt which is uncovered and lit enough.
+func (b *BlockGrass) RandomTick(w TickAccess, x int, y int, z int, r *rand.Rand) {
+	if covered(w, x, y, z) {
+		w.SetBlock(x, y, z, b.dirt)
+		return
+	}
+	tx, ty, tz := x+r.Intn(3)-1, y+r.Intn(5)-3, z+r.Intn(3)-1
+	if t := w.GetBlock(tx, ty, tz); t == nil || t.Name() != b.dirt.Name() || covered(w, tx, ty, tz) {
+		return
+	}
+	if sky, block := w.GetLight(tx, ty+1, tz); sky < GRASS_LIGHT && block < GRASS_LIGHT {
+		return
+	}
+	w.SetBlock(tx, ty, tz, b)
+}
+
+func (b *BlockGrass) New() Block {
+	return b
+}
+
+func (b *BlockGrass) WithState(s BlockState) Block {
+	return b
+}
@@ -0,0 +1,382 @@
+package world
+
+import (
+	"sort"
+)
+
+// MAX_LIGHT is the brightest light level, that of open sky.
+const MAX_LIGHT = 15
+
+// Light levels are stored per position as a byte holding the skylight in
+// its upper and the block light in its lower four bits.
+const (
+	LIGHT_SKY   = 4
+	LIGHT_BLOCK = 0
+)
+
+func lightEmission(b Block) uint8 {
+	if b == nil {
+		return 0
+	}
+	return b.LightEmission()
+}
+
+func (c *Chunk) getLight(i int, shift uint) uint8 {
+	if c.light == nil {
+		return (c.lightFill >> shift) & MAX_LIGHT
+	}
+	return (c.light[i] >> shift) & MAX_LIGHT
+}
+
+func (c *Chunk) setLight(i int, shift uint, v uint8) {
+	if c.light == nil {
+		if (c.lightFill>>shift)&MAX_LIGHT == v {
+			return
+		}
+		c.light = make([]uint8, CHUNK_VOLUME)
+		for j := range c.light {
+			c.light[j] = c.lightFill
+		}
+	}
+	c.light[i] = (c.light[i] &^ (MAX_LIGHT << shift)) | (v << shift)
+}
+
+// compactLight drops the light storage of a chunk lit evenly throughout.
+func (c *Chunk) compactLight() {
+	if c.light == nil {
+		return
+	}
+	for _, v := range c.light {
+		if v != c.light[0] {
+			return
+		}
+	}
+	c.lightFill = c.light[0]
+	c.light = nil
+}
+
+// skyOpen reports whether skylight is assumed to reach y from above when
+// the chunks above it are not loaded. Light let in under terrain which
+// turns out to be above is taken away when its chunk is loaded.
+func skyOpen(y int) bool {
+	return y >= MAP_H
+}
+
+// GetLight returns the skylight and block light levels at the given
+// position. Positions in chunks which are not loaded are dark, unless they
+// are high enough to be taken as open sky.
+func (w *WorldChunked) GetLight(x int, y int, z int) (uint8, uint8) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.getLight(x, y, z)
+}
+
+func (w *WorldChunked) getLight(x int, y int, z int) (uint8, uint8) {
+	if c, ok := w.chunks[chunkPos(x, y, z)]; ok && c.lit {
+		i := chunkIndex(x, y, z)
+		return c.getLight(i, LIGHT_SKY), c.getLight(i, LIGHT_BLOCK)
+	}
+	if skyOpen(y) {
+		return MAX_LIGHT, 0
+	}
+	return 0, 0
+}
+
+type lightNode struct {
+	x, y, z int
+	level   uint8
+}
+
+// lightEngine propagates light through the lit chunks of a world,
+// remembering which chunks it changed.
+type lightEngine struct {
+	w       *WorldChunked
+	lastPos Position
+	last    *Chunk
+	changed map[Position]bool
+}
+
+func newLightEngine(w *WorldChunked) *lightEngine {
+	return &lightEngine{w: w, changed: make(map[Position]bool)}
+}
+
+// chunk returns the lit chunk containing the given position, or nil.
+func (l *lightEngine) chunk(x int, y int, z int) *Chunk {
+	p := chunkPos(x, y, z)
+	if l.last != nil && p == l.lastPos {
+		return l.last
+	}
+	c, ok := l.w.chunks[p]
+	if !ok || !c.lit {
+		return nil
+	}
+	l.lastPos, l.last = p, c
+	return c
+}
+
+func (l *lightEngine) set(c *Chunk, x int, y int, z int, shift uint, v uint8) {
+	c.setLight(chunkIndex(x, y, z), shift, v)
+	p := chunkPos(x, y, z)
+	l.changed[p] = true
+	// light at a chunk's border is seen by the meshes of its neighbors
+	for d := DOWN; d <= FORWARD; d++ {
+		off := d.Offset()
+		if chunkPos(x+off.X, y+off.Y, z+off.Z) != p {
+			l.changed[Position{p.X + off.X, p.Y + off.Y, p.Z + off.Z}] = true
+		}
+	}
+}
+
+// spread floods light outwards from the given nodes, raising the level of
+// every reachable position to at most one less than its neighbor's.
+// Skylight at full strength travels downwards without dimming.
+func (l *lightEngine) spread(queue []lightNode, shift uint) {
+	for i := 0; i < len(queue); i++ {
+		n := queue[i]
+		c := l.chunk(n.x, n.y, n.z)
+		if c == nil {
+			continue
+		}
+		v := c.getLight(chunkIndex(n.x, n.y, n.z), shift)
+		if v <= 1 && !(shift == LIGHT_SKY && v == MAX_LIGHT) {
+			continue
+		}
+		for d := DOWN; d <= FORWARD; d++ {
+			off := d.Offset()
+			x, y, z := n.x+off.X, n.y+off.Y, n.z+off.Z
+			nc := l.chunk(x, y, z)
+			if nc == nil {
+				continue
+			}
+			j := chunkIndex(x, y, z)
+			if IsOpaque(nc.get(j)) {
+				continue
+			}
+			nv := v - 1
+			if shift == LIGHT_SKY && d == DOWN && v == MAX_LIGHT {
+				nv = MAX_LIGHT
+			}
+			if nc.getLight(j, shift) < nv {
+				l.set(nc, x, y, z, shift, nv)
+				queue = append(queue, lightNode{x, y, z, nv})
+			}
+		}
+	}
+}
+
+// unspread darkens every position whose light came from the given nodes,
+// which hold the levels they had before being darkened. It returns the
+// positions bordering the darkened area from which light has to be spread
+// again.
+func (l *lightEngine) unspread(queue []lightNode, shift uint) []lightNode {
+	var relight []lightNode
+	for i := 0; i < len(queue); i++ {
+		n := queue[i]
+		for d := DOWN; d <= FORWARD; d++ {
+			off := d.Offset()
+			x, y, z := n.x+off.X, n.y+off.Y, n.z+off.Z
+			nc := l.chunk(x, y, z)
+			if nc == nil {
+				continue
+			}
+			j := chunkIndex(x, y, z)
+			nv := nc.getLight(j, shift)
+			if nv == 0 {
+				continue
+			}
+			if nv < n.level || (shift == LIGHT_SKY && d == DOWN && n.level == MAX_LIGHT && nv == MAX_LIGHT) {
+				l.set(nc, x, y, z, shift, 0)
+				queue = append(queue, lightNode{x, y, z, nv})
+				if e := lightEmission(nc.get(j)); shift == LIGHT_BLOCK && e > 0 {
+					l.set(nc, x, y, z, shift, e)
+					relight = append(relight, lightNode{x, y, z, e})
+				}
+			} else {
+				relight = append(relight, lightNode{x, y, z, nv})
+			}
+		}
+	}
+	return relight
+}
+
+// notify tells the render listeners about the chunks whose light changed,
+// leaving out those in skip.
+func (l *lightEngine) notify(skip ...Position) {
+	for _, p := range skip {
+		delete(l.changed, p)
+	}
+	for p := range l.changed {
+		if _, ok := l.w.chunks[p]; !ok {
+			continue
+		}
+		center := Position{p.X<<CHUNK_SHIFT + CHUNK_SIZE/2, p.Y<<CHUNK_SHIFT + CHUNK_SIZE/2, p.Z<<CHUNK_SHIFT + CHUNK_SIZE/2}
+		l.w.events = append(l.w.events, worldEvent{pos: center})
+	}
+}
+
+// lightChunk computes the light of a newly loaded chunk, pulling in light
+// from the lit chunks around it and spreading its own light into them.
+func (w *WorldChunked) lightChunk(p Position) {
+	c := w.chunks[p]
+	c.lit = true
+	c.light = nil
+	c.lightFill = 0
+	l := newLightEngine(w)
+	base := Position{p.X << CHUNK_SHIFT, p.Y << CHUNK_SHIFT, p.Z << CHUNK_SHIFT}
+
+	var sky, block []lightNode
+	above := w.chunks[Position{p.X, p.Y + 1, p.Z}]
+	// the chunk below may have been lit as if the sky was open, while this
+	// chunk blocks it
+	if below := l.chunk(base.X, base.Y-1, base.Z); below != nil {
+		var dark []lightNode
+		y := base.Y - 1
+		for z := 0; z < CHUNK_SIZE; z++ {
+			for x := 0; x < CHUNK_SIZE; x++ {
+				if below.getLight(chunkIndex(x, y, z), LIGHT_SKY) == MAX_LIGHT && !skyThrough(c, above, base, x, z) {
+					l.set(below, base.X+x, y, base.Z+z, LIGHT_SKY, 0)
+					dark = append(dark, lightNode{base.X + x, y, base.Z + z, MAX_LIGHT})
+				}
+			}
+		}
+		sky = l.unspread(dark, LIGHT_SKY)
+	}
+	if (above == nil || !above.lit) && skyOpen(base.Y+CHUNK_SIZE) {
+		for z := 0; z < CHUNK_SIZE; z++ {
+			for x := 0; x < CHUNK_SIZE; x++ {
+				y := base.Y + CHUNK_SIZE - 1
+				if !IsOpaque(c.get(chunkIndex(x, y, z))) {
+					l.set(c, base.X+x, y, base.Z+z, LIGHT_SKY, MAX_LIGHT)
+					sky = append(sky, lightNode{base.X + x, y, base.Z + z, MAX_LIGHT})
+				}
+			}
+		}
+	}
+
+	// the borders of lit neighbors
+	for d := DOWN; d <= FORWARD; d++ {
+		off := d.Offset()
+		nc := l.chunk(base.X+off.X*CHUNK_SIZE, base.Y+off.Y*CHUNK_SIZE, base.Z+off.Z*CHUNK_SIZE)
+		if nc == nil {
+			continue
+		}
+		for a := 0; a < CHUNK_SIZE; a++ {
+			for b := 0; b < CHUNK_SIZE; b++ {
+				var x, y, z int
+				switch d {
+				case DOWN, UP:
+					x, y, z = a, (int(d)&1)*(CHUNK_SIZE+1)-1, b
+				case LEFT, RIGHT:
+					x, y, z = (int(d)&1)*(CHUNK_SIZE+1)-1, a, b
+				default:
+					x, y, z = a, b, (int(d)&1)*(CHUNK_SIZE+1)-1
+				}
+				x, y, z = base.X+x, base.Y+y, base.Z+z
+				i := chunkIndex(x, y, z)
+				if v := nc.getLight(i, LIGHT_SKY); v > 1 || (d == UP && v == MAX_LIGHT) {
+					sky = append(sky, lightNode{x, y, z, v})
+				}
+				if v := nc.getLight(i, LIGHT_BLOCK); v > 1 {
+					block = append(block, lightNode{x, y, z, v})
+				}
+			}
+		}
+	}
+
+	if !c.IsEmpty() {
+		for i := 0; i < CHUNK_VOLUME; i++ {
+			if e := lightEmission(c.get(i)); e > 0 {
+				x, y, z := base.X+i&CHUNK_MASK, base.Y+i>>(2*CHUNK_SHIFT), base.Z+(i>>CHUNK_SHIFT)&CHUNK_MASK
+				l.set(c, x, y, z, LIGHT_BLOCK, e)
+				block = append(block, lightNode{x, y, z, e})
+			}
+		}
+	}
+
+	l.spread(sky, LIGHT_SKY)
+	l.spread(block, LIGHT_BLOCK)
+	for cp := range l.changed {
+		if cc, ok := w.chunks[cp]; ok {
+			cc.compactLight()
+		}
+	}
+	// the chunk and its direct neighbors are remeshed when it is loaded
+	skip := []Position{p}
+	for d := DOWN; d <= FORWARD; d++ {
+		off := d.Offset()
+		skip = append(skip, Position{p.X + off.X, p.Y + off.Y, p.Z + off.Z})
+	}
+	l.notify(skip...)
+}
+
+// skyThrough reports whether full skylight reaches through the column x, z
+// of the chunk c, at base, from the chunk above it.
+func skyThrough(c *Chunk, above *Chunk, base Position, x int, z int) bool {
+	if above == nil || !above.lit {
+		if !skyOpen(base.Y + CHUNK_SIZE) {
+			return false
+		}
+	} else if above.getLight(chunkIndex(x, 0, z), LIGHT_SKY) != MAX_LIGHT {
+		return false
+	}
+	for y := 0; y < CHUNK_SIZE; y++ {
+		if IsOpaque(c.get(chunkIndex(x, y, z))) {
+			return false
+		}
+	}
+	return true
+}
+
+// lightAll lights every resident chunk.
+func (w *WorldChunked) lightAll() {
+	chunks := make([]Position, 0, len(w.chunks))
+	for p := range w.chunks {
+		chunks = append(chunks, p)
+	}
+	w.lightChunks(chunks)
+}
+
+// lightChunks lights the given chunks, topmost first so that skylight has
+// to be spread only once.
+func (w *WorldChunked) lightChunks(chunks []Position) {
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].Y > chunks[j].Y
+	})
+	for _, p := range chunks {
+		w.lightChunk(p)
+	}
+}
+
+// updateLight recomputes the light around a position whose block changed.
+func (w *WorldChunked) updateLight(x int, y int, z int) {
+	l := newLightEngine(w)
+	c := l.chunk(x, y, z)
+	if c == nil {
+		return
+	}
+	i := chunkIndex(x, y, z)
+	b := c.get(i)
+	for _, shift := range [2]uint{LIGHT_SKY, LIGHT_BLOCK} {
+		old := c.getLight(i, shift)
+		l.set(c, x, y, z, shift, 0)
+		relight := l.unspread([]lightNode{{x, y, z, old}}, shift)
+		if e := lightEmission(b); shift == LIGHT_BLOCK && e > 0 {
+			l.set(c, x, y, z, shift, e)
+			relight = append(relight, lightNode{x, y, z, e})
+		}
+		if !IsOpaque(b) {
+			// let light back in from the neighbors
+			for d := DOWN; d <= FORWARD; d++ {
+				off := d.Offset()
+				if nc := l.chunk(x+off.X, y+off.Y, z+off.Z); nc != nil {
+					if v := nc.getLight(chunkIndex(x+off.X, y+off.Y, z+off.Z), shift); v > 0 {
+						relight = append(relight, lightNode{x + off.X, y + off.Y, z + off.Z, v})
+					}
+				}
+			}
+		}
+		l.spread(relight, shift)
+	}
+	// the changed block's own chunk is remeshed by SetBlock
+	l.notify(chunkPos(x, y, z))
+}
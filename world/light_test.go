@@ -0,0 +1,210 @@
+package world
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// newLightWorld returns an empty world with a 3x3 column of chunks loaded
+// bottom up, the worst order for skylight.
+func newLightWorld() (*WorldChunked, BlockRegistry) {
+	br := NewBlockRegistry()
+	br.Register(NewBlockSimple("stone", [6]string{}))
+	br.Register(NewBlockLight("lamp", [6]string{}, MAX_LIGHT))
+	br.Register(NewBlockSlab("slab", [6]string{}))
+	w := NewWorldChunked(br, &GeneratorVoid{})
+	for y := 0; y <= MAP_H>>CHUNK_SHIFT; y++ {
+		for z := -1; z <= 1; z++ {
+			for x := -1; x <= 1; x++ {
+				w.LoadChunk(Position{x, y, z})
+			}
+		}
+	}
+	return &w, br
+}
+
+func skyLight(w LightAccess, x int, y int, z int) uint8 {
+	sky, _ := w.GetLight(x, y, z)
+	return sky
+}
+
+func blockLight(w LightAccess, x int, y int, z int) uint8 {
+	_, block := w.GetLight(x, y, z)
+	return block
+}
+
+// lightOf returns the light of every position in the loaded chunks of w.
+func lightOf(w *WorldChunked) map[Position][CHUNK_VOLUME]uint8 {
+	m := make(map[Position][CHUNK_VOLUME]uint8, len(w.chunks))
+	for p, c := range w.chunks {
+		var a [CHUNK_VOLUME]uint8
+		for i := range a {
+			a[i] = c.getLight(i, LIGHT_SKY)<<LIGHT_SKY | c.getLight(i, LIGHT_BLOCK)
+		}
+		m[p] = a
+	}
+	return m
+}
+
+// compareLight reports the positions where the light of got differs from
+// that of want.
+func compareLight(t *testing.T, got map[Position][CHUNK_VOLUME]uint8, want map[Position][CHUNK_VOLUME]uint8) {
+	t.Helper()
+	bad := 0
+	for p, a := range want {
+		b := got[p]
+		for i := range a {
+			if a[i] != b[i] {
+				if bad++; bad <= 5 {
+					t.Errorf("chunk %v index %d: light %#x, want %#x", p, i, b[i], a[i])
+				}
+			}
+		}
+	}
+	if bad > 5 {
+		t.Errorf("%d positions differ", bad)
+	}
+}
+
+func TestSkyLight(t *testing.T) {
+	w, br := newLightWorld()
+	stone := br.ByName("stone")
+	if s := skyLight(w, 0, 5, 0); s != MAX_LIGHT {
+		t.Fatalf("open sky: %d", s)
+	}
+	for x := -2; x <= 2; x++ {
+		for z := -2; z <= 2; z++ {
+			w.SetBlock(x, 10, z, stone)
+		}
+	}
+	for _, c := range []struct {
+		y    int
+		want uint8
+	}{{9, 12}, {2, 12}, {11, MAX_LIGHT}} {
+		if s := skyLight(w, 0, c.y, 0); s != c.want {
+			t.Errorf("under a roof, y=%d: %d, want %d", c.y, s, c.want)
+		}
+	}
+	w.SetBlock(0, 10, 0, nil)
+	if s := skyLight(w, 0, 9, 0); s != MAX_LIGHT {
+		t.Errorf("under a hole: %d", s)
+	}
+	if s := skyLight(w, 1, 9, 0); s != MAX_LIGHT-1 {
+		t.Errorf("beside a hole: %d", s)
+	}
+}
+
+func TestBlockLight(t *testing.T) {
+	w, br := newLightWorld()
+	stone, lamp := br.ByName("stone"), br.ByName("lamp")
+	// a sealed box
+	for x := -3; x <= 3; x++ {
+		for y := 57; y <= 63; y++ {
+			for z := -3; z <= 3; z++ {
+				if x == -3 || x == 3 || y == 57 || y == 63 || z == -3 || z == 3 {
+					w.SetBlock(x, y, z, stone)
+				}
+			}
+		}
+	}
+	if s := skyLight(w, 0, 60, 0); s != 0 {
+		t.Errorf("sky in the box: %d", s)
+	}
+	w.SetBlock(0, 60, 0, lamp)
+	if b := blockLight(w, 2, 60, 0); b != 13 {
+		t.Errorf("beside the lamp: %d", b)
+	}
+	if b := blockLight(w, 4, 60, 0); b != 0 {
+		t.Errorf("outside the box: %d", b)
+	}
+	w.SetBlock(0, 60, 0, nil)
+	if b := blockLight(w, 2, 60, 0); b != 0 {
+		t.Errorf("lamp removed: %d", b)
+	}
+	w.SetBlock(0, 60, 0, lamp)
+	w.SetBlock(0, 63, 0, nil)
+	if s := skyLight(w, 0, 59, 1); s != 12 {
+		t.Errorf("sky through the lid: %d", s)
+	}
+	if b := blockLight(w, 0, 64, 0); b != 11 {
+		t.Errorf("lamp through the lid: %d", b)
+	}
+}
+
+// TestLightIncremental checks that the light kept up to date block by block
+// matches that of the whole world lit from scratch.
+func TestLightIncremental(t *testing.T) {
+	w, br := newLightWorld()
+	blocks := []Block{nil, br.ByName("stone"), br.ByName("stone"), br.ByName("lamp"), br.ByName("slab")}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 6000; i++ {
+		x, y, z := r.Intn(40)-20, r.Intn(40)+50, r.Intn(40)-20
+		if i > 3000 {
+			x, y, z = r.Intn(12)-6, r.Intn(12)+55, r.Intn(12)-6
+		}
+		w.SetBlock(x, y, z, blocks[r.Intn(len(blocks))])
+	}
+	got := lightOf(w)
+	w.lightAll()
+	compareLight(t, got, lightOf(w))
+}
+
+// TestLightLoadOrder checks that skylight doesn't depend on the order the
+// chunks are loaded in, under a roof higher than MAP_H.
+func TestLightLoadOrder(t *testing.T) {
+	roof := MAP_H + 2
+	preset := fmt.Sprintf("%d*air,4*stone", roof)
+	load := func(order []int) *WorldChunked {
+		br := NewBlockRegistry()
+		br.Register(NewBlockSimple("stone", [6]string{}))
+		g, err := NewGeneratorFlat(br, 1, preset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := NewWorldChunked(br, g)
+		for _, y := range order {
+			for z := -1; z <= 1; z++ {
+				for x := -1; x <= 1; x++ {
+					w.LoadChunk(Position{x, y, z})
+				}
+			}
+		}
+		return &w
+	}
+	down := load([]int{9, 8, 7, 6})
+	up := load([]int{6, 7, 8, 9})
+	if s := skyLight(up, 0, 120, 0); s != 0 {
+		t.Errorf("under the roof, loaded bottom up: %d", s)
+	}
+	if s := skyLight(up, 0, roof+4, 0); s != MAX_LIGHT {
+		t.Errorf("above the roof, loaded bottom up: %d", s)
+	}
+	compareLight(t, lightOf(up), lightOf(down))
+	mixed := load([]int{7, 9, 6, 8})
+	compareLight(t, lightOf(mixed), lightOf(down))
+}
+
+func TestLightSaved(t *testing.T) {
+	w, br := newLightWorld()
+	w.SetBlock(0, 60, 0, br.ByName("lamp"))
+	for x := -2; x <= 2; x++ {
+		for z := -2; z <= 2; z++ {
+			w.SetBlock(x, 70, z, br.ByName("stone"))
+		}
+	}
+	dir := t.TempDir()
+	if err := SaveWorld(w, dir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadWorld(dir, br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := skyLight(&loaded, 0, 69, 0), skyLight(w, 0, 69, 0); got != want {
+		t.Errorf("sky under the roof: %d, want %d", got, want)
+	}
+	if b := blockLight(&loaded, 2, 60, 0); b != 13 {
+		t.Errorf("beside the lamp: %d, want 13", b)
+	}
+}
@@ -0,0 +1,97 @@
+package world
+
+import (
+	"github.com/barnex/fmath"
+)
+
+type Vec4 [4]float32
+
+// Mat4 is a 4x4 matrix stored in column-major order, as OpenGL expects:
+// the element in row r and column c is at index c*4+r.
+type Mat4 [16]float32
+
+// Ident4 returns the identity matrix.
+func Ident4() Mat4 {
+	return Mat4{
+		1, 0, 0, 0,
+		0, 1, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// At returns the element in row r and column c.
+func (m Mat4) At(r int, c int) float32 {
+	return m[c*4+r]
+}
+
+// Mul returns the product m * n, which applies n first, then m.
+func (m Mat4) Mul(n Mat4) Mat4 {
+	var p Mat4
+	for c := 0; c < 4; c++ {
+		for r := 0; r < 4; r++ {
+			var s float32
+			for k := 0; k < 4; k++ {
+				s += m[k*4+r] * n[c*4+k]
+			}
+			p[c*4+r] = s
+		}
+	}
+	return p
+}
+
+// Transform returns the product m * v.
+func (m Mat4) Transform(v Vec4) Vec4 {
+	var p Vec4
+	for r := 0; r < 4; r++ {
+		p[r] = m[r]*v[0] + m[4+r]*v[1] + m[8+r]*v[2] + m[12+r]*v[3]
+	}
+	return p
+}
+
+// TransformPoint transforms the point v by m, dividing by the resulting w.
+func (m Mat4) TransformPoint(v Vec3) Vec3 {
+	p := m.Transform(Vec4{v[0], v[1], v[2], 1})
+	return Vec3{p[0] / p[3], p[1] / p[3], p[2] / p[3]}
+}
+
+// Translate4 returns a matrix translating by v.
+func Translate4(v Vec3) Mat4 {
+	m := Ident4()
+	m[12], m[13], m[14] = v[0], v[1], v[2]
+	return m
+}
+
+// RotateX4 returns a matrix rotating by angle radians around the X axis,
+// like glRotatef(angle, 1, 0, 0).
+func RotateX4(angle float32) Mat4 {
+	s, c := fmath.Sin(angle), fmath.Cos(angle)
+	return Mat4{
+		1, 0, 0, 0,
+		0, c, s, 0,
+		0, -s, c, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// RotateY4 returns a matrix rotating by angle radians around the Y axis,
+// like glRotatef(angle, 0, 1, 0).
+func RotateY4(angle float32) Mat4 {
+	s, c := fmath.Sin(angle), fmath.Cos(angle)
+	return Mat4{
+		c, 0, -s, 0,
+		0, 1, 0, 0,
+		s, 0, c, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// Frustum4 returns a perspective projection matrix, like glFrustum.
+func Frustum4(left float32, right float32, bottom float32, top float32, near float32, far float32) Mat4 {
+	return Mat4{
+		2 * near / (right - left), 0, 0, 0,
+		0, 2 * near / (top - bottom), 0, 0,
+		(right + left) / (right - left), (top + bottom) / (top - bottom), -(far + near) / (far - near), -1,
+		0, 0, -2 * far * near / (far - near), 0,
+	}
+}
@@ -0,0 +1,104 @@
+package world
+
+import (
+	"math"
+	"testing"
+)
+
+func nearMat(a Mat4, b Mat4) bool {
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-5 {
+			return false
+		}
+	}
+	return true
+}
+
+func nearVec3(a Vec3, b Vec3) bool {
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-4 {
+			return false
+		}
+	}
+	return true
+}
+
+// glRotate builds the matrix of glRotatef(angle, x, y, z) for a unit axis,
+// as given in the OpenGL 2.1 reference, with angle in radians.
+func glRotate(angle float64, x float64, y float64, z float64) Mat4 {
+	c, s := math.Cos(angle), math.Sin(angle)
+	rows := [4][4]float64{
+		{x*x*(1-c) + c, x*y*(1-c) - z*s, x*z*(1-c) + y*s, 0},
+		{y*x*(1-c) + z*s, y*y*(1-c) + c, y*z*(1-c) - x*s, 0},
+		{x*z*(1-c) - y*s, y*z*(1-c) + x*s, z*z*(1-c) + c, 0},
+		{0, 0, 0, 1},
+	}
+	var m Mat4
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			m[c*4+r] = float32(rows[r][c])
+		}
+	}
+	return m
+}
+
+func TestRotate4(t *testing.T) {
+	for _, a := range []float64{0, 0.3, -1.2, math.Pi / 2, 2.5} {
+		if got, want := RotateX4(float32(a)), glRotate(a, 1, 0, 0); !nearMat(got, want) {
+			t.Errorf("RotateX4(%v) = %v, want %v", a, got, want)
+		}
+		if got, want := RotateY4(float32(a)), glRotate(a, 0, 1, 0); !nearMat(got, want) {
+			t.Errorf("RotateY4(%v) = %v, want %v", a, got, want)
+		}
+	}
+	if p := RotateY4(math.Pi / 2).TransformPoint(Vec3{0, 0, -1}); !nearVec3(p, Vec3{-1, 0, 0}) {
+		t.Errorf("RotateY4 turned -z to %v", p)
+	}
+}
+
+func TestFrustum4(t *testing.T) {
+	l, r, b, tp, n, f := float32(-0.02), float32(0.01), float32(-0.01), float32(0.015), float32(0.01), float32(512)
+	// glFrustum, as given in the OpenGL 2.1 reference
+	rows := [4][4]float32{
+		{2 * n / (r - l), 0, (r + l) / (r - l), 0},
+		{0, 2 * n / (tp - b), (tp + b) / (tp - b), 0},
+		{0, 0, -(f + n) / (f - n), -2 * f * n / (f - n)},
+		{0, 0, -1, 0},
+	}
+	m := Frustum4(l, r, b, tp, n, f)
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			if d := m.At(row, col) - rows[row][col]; d > 1e-4 || d < -1e-4 {
+				t.Errorf("element %d, %d is %v, want %v", row, col, m.At(row, col), rows[row][col])
+			}
+		}
+	}
+	// the corners of the near and far planes map to those of the clip cube
+	sym := Frustum4(-1, 1, -1, 1, 1, 100)
+	if p := sym.TransformPoint(Vec3{-1, 1, -1}); !nearVec3(p, Vec3{-1, 1, -1}) {
+		t.Errorf("near corner at %v", p)
+	}
+	if p := sym.TransformPoint(Vec3{100, -100, -100}); !nearVec3(p, Vec3{1, -1, 1}) {
+		t.Errorf("far corner at %v", p)
+	}
+}
+
+func TestMul(t *testing.T) {
+	rot, move := RotateY4(math.Pi/2), Translate4(Vec3{1, 2, 3})
+	m := rot.Mul(move)
+	if Ident4().Mul(m) != m || m.Mul(Ident4()) != m {
+		t.Error("multiplying by the identity changed the matrix")
+	}
+	// the translation applies first, then the rotation
+	if p := m.TransformPoint(Vec3{0, 0, 0}); !nearVec3(p, Vec3{3, 2, -1}) {
+		t.Errorf("origin moved to %v, want 3, 2, -1", p)
+	}
+	v := Vec4{0.5, -2, 4, 1}
+	got, want := m.Transform(v), rot.Transform(move.Transform(v))
+	if !nearVec3(Vec3{got[0], got[1], got[2]}, Vec3{want[0], want[1], want[2]}) || got[3] != want[3] {
+		t.Errorf("m.Transform(v) = %v, want rot.Transform(move.Transform(v)) = %v", got, want)
+	}
+	if p := move.Mul(rot).TransformPoint(Vec3{0, 0, 0}); !nearVec3(p, Vec3{1, 2, 3}) {
+		t.Errorf("rotated then moved origin at %v, want 1, 2, 3", p)
+	}
+}
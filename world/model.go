@@ -0,0 +1,155 @@
+package world
+
+import (
+	"sync"
+)
+
+type Texture struct {
+	Binding uint32
+	MinU float32
+	MaxU float32
+	MinV float32
+	MaxV float32
+}
+
+// TextureAtlas resolves texture file names to their location in a texture
+// sheet. Blocks build their models against one.
+type TextureAtlas interface {
+	Texture(name string) Texture
+}
+
+type Vertex struct {
+	Coord    Vec3
+	Texcoord Vec2
+	Color    Vec3
+}
+
+type Quad struct {
+	V      [4]Vertex
+	Normal Vec3
+}
+
+type Model struct {
+	FaceQuads [6][]Quad
+	Quads []Quad
+}
+
+// modelCache holds the models a block built for each texture atlas. Blocks
+// are shared by every chunk and mesher, so it is safe for concurrent use.
+type modelCache struct {
+	lock   sync.Mutex
+	models map[TextureAtlas]Model
+}
+
+// get returns the model built for a, building it first if needed.
+func (c *modelCache) get(a TextureAtlas, build func() Model) Model {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if v, ok := c.models[a]; ok {
+		return v
+	}
+	if c.models == nil {
+		c.models = make(map[TextureAtlas]Model, 1)
+	}
+	c.models[a] = build()
+	return c.models[a]
+}
+
+func getQuadDirection(q *Quad) Direction {
+	m := []int{2, 3, 0, 1, 4, 5}
+	var d Direction
+	var occ int
+	for i := 0; i < 3; i++ {
+		j := q.V[0].Coord[i]
+		if (j == 0 || j == 1) && j == q.V[1].Coord[i] && q.V[1].Coord[i] == q.V[2].Coord[i] && q.V[2].Coord[i] == q.V[3].Coord[i] {
+				occ++
+				d = Direction(m[i * 2 + int(j)])
+			}
+	}
+	if occ == 1 {
+		return d
+	} else {
+		return UNKNOWN
+	}
+}
+
+func (m *Model) AddQuad(q Quad) {
+	d := getQuadDirection(&q)
+	if d == UNKNOWN {
+		m.Quads = append(m.Quads, q)
+	} else {
+		m.FaceQuads[int(d)] = append(m.FaceQuads[int(d)], q)
+	}
+}
+
+func NewFullCubeModel(ta [6]Texture) Model {
+	return NewCubeModel(ta, Vec3{0, 0, 0}, Vec3{1, 1, 1})
+}
+
+func NewCubeModel(ta [6]Texture, min Vec3, max Vec3) Model {
+	m := Model{}
+	q := Quad{}
+	c := Vec3{1, 1, 1}
+
+	t := ta[5]
+	q.Normal = Vec3{0, 0, 1}
+	q.V = [4]Vertex{
+		Vertex{Coord: Vec3{min[0], max[1], max[2]}, Texcoord: Vec2{t.MinU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{max[0], max[1], max[2]}, Texcoord: Vec2{t.MaxU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{max[0], min[1], max[2]}, Texcoord: Vec2{t.MaxU, t.MaxV}, Color: c},
+		Vertex{Coord: Vec3{min[0], min[1], max[2]}, Texcoord: Vec2{t.MinU, t.MaxV}, Color: c},
+	}
+	m.AddQuad(q)
+
+	t = ta[4]
+	q.Normal = Vec3{0, 0, -1}
+	q.V = [4]Vertex{
+		Vertex{Coord: Vec3{min[0], max[1], min[2]}, Texcoord: Vec2{t.MinU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{max[0], max[1], min[2]}, Texcoord: Vec2{t.MaxU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{max[0], min[1], min[2]}, Texcoord: Vec2{t.MaxU, t.MaxV}, Color: c},
+		Vertex{Coord: Vec3{min[0], min[1], min[2]}, Texcoord: Vec2{t.MinU, t.MaxV}, Color: c},
+	}
+	m.AddQuad(q)
+
+	t = ta[1]
+	q.Normal = Vec3{0, 0, 1}
+	q.V = [4]Vertex{
+		Vertex{Coord: Vec3{min[0], max[1], min[2]}, Texcoord: Vec2{t.MinU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{max[0], max[1], min[2]}, Texcoord: Vec2{t.MaxU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{max[0], max[1], max[2]}, Texcoord: Vec2{t.MaxU, t.MaxV}, Color: c},
+		Vertex{Coord: Vec3{min[0], max[1], max[2]}, Texcoord: Vec2{t.MinU, t.MaxV}, Color: c},
+	}
+	m.AddQuad(q)
+
+	t = ta[0]
+	q.Normal = Vec3{0, 0, -1}
+	q.V = [4]Vertex{
+		Vertex{Coord: Vec3{min[0], min[1], min[2]}, Texcoord: Vec2{t.MinU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{max[0], min[1], min[2]}, Texcoord: Vec2{t.MaxU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{max[0], min[1], max[2]}, Texcoord: Vec2{t.MaxU, t.MaxV}, Color: c},
+		Vertex{Coord: Vec3{min[0], min[1], max[2]}, Texcoord: Vec2{t.MinU, t.MaxV}, Color: c},
+	}
+	m.AddQuad(q)
+
+	t = ta[3]
+	q.Normal = Vec3{1, 0, 0}
+	q.V = [4]Vertex{
+		Vertex{Coord: Vec3{max[0], max[1], min[2]}, Texcoord: Vec2{t.MinU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{max[0], max[1], max[2]}, Texcoord: Vec2{t.MaxU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{max[0], min[1], max[2]}, Texcoord: Vec2{t.MaxU, t.MaxV}, Color: c},
+		Vertex{Coord: Vec3{max[0], min[1], min[2]}, Texcoord: Vec2{t.MinU, t.MaxV}, Color: c},
+	}
+	m.AddQuad(q)
+
+	t = ta[2]
+	q.Normal = Vec3{-1, 0, 0}
+	q.V = [4]Vertex{
+		Vertex{Coord: Vec3{min[0], max[1], min[2]}, Texcoord: Vec2{t.MinU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{min[0], max[1], max[2]}, Texcoord: Vec2{t.MaxU, t.MinV}, Color: c},
+		Vertex{Coord: Vec3{min[0], min[1], max[2]}, Texcoord: Vec2{t.MaxU, t.MaxV}, Color: c},
+		Vertex{Coord: Vec3{min[0], min[1], min[2]}, Texcoord: Vec2{t.MinU, t.MaxV}, Color: c},
+	}
+	m.AddQuad(q)
+
+	return m
+}
@@ -0,0 +1,41 @@
+package world
+
+import (
+	"sync"
+	"testing"
+)
+
+type testAtlas struct{}
+
+func (testAtlas) Texture(name string) Texture {
+	return Texture{MaxU: 1, MaxV: 1}
+}
+
+// TestGetModelConcurrent builds the models of fresh blocks from several
+// goroutines at once, as the meshers do; run it with -race.
+func TestGetModelConcurrent(t *testing.T) {
+	blocks := []Block{
+		NewBlockSimple("stone", [6]string{}),
+		NewBlockSlab("slab", [6]string{}),
+		NewBlockLog("log", "top", "side"),
+		NewBlockFluid("water", "water", LAYER_TRANSLUCENT, 0, 7, 5),
+	}
+	var wg sync.WaitGroup
+	models := make([][]Model, 4)
+	for i := range models {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, b := range blocks {
+				models[i] = append(models[i], b.GetModel(testAtlas{}))
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, b := range blocks {
+		m := models[0][i]
+		if n := len(m.FaceQuads[DOWN]) + len(m.FaceQuads[UP]) + len(m.Quads); n != 2 {
+			t.Errorf("%s: %d top and bottom quads, want 2", b.Name(), n)
+		}
+	}
+}
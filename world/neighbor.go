@@ -0,0 +1,120 @@
+package world
+
+import (
+	"sync"
+)
+
+// DEFAULT_CASCADE_LIMIT is the default number of times neighbor changes
+// may set off further block changes in a row.
+const DEFAULT_CASCADE_LIMIT = 64
+
+// NeighborListener is implemented by blocks which react to the blocks next
+// to them changing, such as blocks which need support.
+type NeighborListener interface {
+	// OnNeighborChange is called on the block at x, y, z after the block
+	// on its side from was set, and with from UNKNOWN after it was set
+	// itself.
+	OnNeighborChange(w TickAccess, x int, y int, z int, from Direction)
+}
+
+// neighborChange is a block change whose neighbors are waiting to be told
+// about it. depth counts the changes which led to it.
+type neighborChange struct {
+	pos   Position
+	depth int
+}
+
+// neighborQueue tells the neighbors of changed blocks about the changes.
+// Changes made by the listeners are queued rather than dispatched from
+// within them, so that listeners never run inside each other and a chain
+// of changes can't overflow the stack; those more than limit changes deep
+// are dropped.
+type neighborQueue struct {
+	lock  sync.Mutex
+	limit int
+	queue []neighborChange
+	// dispatching is set while a goroutine is telling the neighbors about
+	// the queued changes, and depth is that of the change it is on
+	dispatching bool
+	depth       int
+	// ticks is given to the listeners, if set by a Scheduler
+	ticks TickAccess
+}
+
+func newNeighborQueue() *neighborQueue {
+	return &neighborQueue{limit: DEFAULT_CASCADE_LIMIT}
+}
+
+func (q *neighborQueue) setLimit(limit int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.limit = limit
+}
+
+func (q *neighborQueue) setTicks(t TickAccess) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.ticks = t
+}
+
+// changed tells the neighbors of the block at p, which was set in w, about
+// the change. Changes made while the neighbors of another are being told
+// are dispatched once that is done.
+func (q *neighborQueue) changed(w World, p Position) {
+	q.lock.Lock()
+	depth := 0
+	if q.dispatching {
+		depth = q.depth + 1
+	}
+	if depth <= q.limit {
+		q.queue = append(q.queue, neighborChange{p, depth})
+	}
+	if q.dispatching {
+		q.lock.Unlock()
+		return
+	}
+	q.dispatching = true
+	ticks := q.ticks
+	if ticks == nil {
+		ticks = unscheduled{w}
+	}
+	for len(q.queue) > 0 {
+		c := q.queue[0]
+		q.queue = q.queue[1:]
+		q.depth = c.depth
+		q.lock.Unlock()
+		// the changed block itself is told last, as its own neighbor UNKNOWN
+		for d := DOWN; d <= UNKNOWN; d++ {
+			off := d.Offset()
+			x, y, z := c.pos.X+off.X, c.pos.Y+off.Y, c.pos.Z+off.Z
+			from := d ^ 1
+			if d == UNKNOWN {
+				from = UNKNOWN
+			}
+			if b, ok := w.GetBlock(x, y, z).(NeighborListener); ok {
+				b.OnNeighborChange(ticks, x, y, z, from)
+			}
+		}
+		q.lock.Lock()
+	}
+	q.dispatching = false
+	q.queue = nil
+	q.lock.Unlock()
+}
+
+// unscheduled gives the blocks of worlds without a Scheduler access to
+// them as a TickAccess. Such worlds are fully lit, and the ticks blocks
+// schedule in them are dropped.
+type unscheduled struct {
+	World
+}
+
+func (u unscheduled) GetLight(x int, y int, z int) (uint8, uint8) {
+	if la, ok := u.World.(LightAccess); ok {
+		return la.GetLight(x, y, z)
+	}
+	return MAX_LIGHT, 0
+}
+
+func (u unscheduled) ScheduleTick(x int, y int, z int, delay int, priority int) {
+}
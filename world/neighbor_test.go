@@ -0,0 +1,167 @@
+package world
+
+import (
+	"reflect"
+	"testing"
+)
+
+// flipper swaps itself for its other half when the block below it
+// changes, which tells the block above, so a column of them flips all the
+// way up. It logs the positions it flips at, and the deepest it was
+// called from within itself.
+type flipper struct {
+	*BlockSimple
+	other *flipper
+	state *flipState
+}
+
+type flipState struct {
+	log     []Position
+	calls   int
+	nesting int
+	self    []Position
+}
+
+func (b *flipper) New() Block {
+	return b
+}
+
+func (b *flipper) OnNeighborChange(w TickAccess, x int, y int, z int, from Direction) {
+	s := b.state
+	s.calls++
+	defer func() { s.calls-- }()
+	if s.calls > s.nesting {
+		s.nesting = s.calls
+	}
+	switch from {
+	case DOWN:
+		s.log = append(s.log, Position{x, y, z})
+		w.SetBlock(x, y, z, b.other)
+	case UNKNOWN:
+		s.self = append(s.self, Position{x, y, z})
+	}
+}
+
+// newFlipColumn returns a world holding a column of flippers from y = 1 to
+// height-1, on top of a stone at y = 0.
+func newFlipColumn(height int) (*WorldChunked, *flipper, *flipper, *flipState) {
+	w := NewWorldChunked(NewBlockRegistry(), nil)
+	state := &flipState{}
+	a := &flipper{BlockSimple: NewBlockSimple("a", [6]string{}), state: state}
+	b := &flipper{BlockSimple: NewBlockSimple("b", [6]string{}), other: a, state: state}
+	a.other = b
+	w.SetBlock(0, 0, 0, testStone)
+	for y := 1; y < height; y++ {
+		w.SetBlock(0, y, 0, a)
+	}
+	*state = flipState{}
+	return &w, a, b, state
+}
+
+func TestNeighborCascade(t *testing.T) {
+	w, a, b, state := newFlipColumn(30)
+	w.SetCascadeLimit(5)
+	w.SetBlock(0, 0, 0, nil)
+	// the change at y = 0 flips y = 1 one change deep, and so on; the
+	// flip at y = 6 is past the limit, so y = 7 isn't told about it
+	if len(state.log) != 6 {
+		t.Errorf("%d flips, want 6", len(state.log))
+	}
+	for y := 1; y < 30; y++ {
+		want := a
+		if y <= 6 {
+			want = b
+		}
+		if got := w.GetBlock(0, y, 0); got != Block(want) {
+			t.Errorf("y = %d: %s, want %s", y, got.Name(), want.Name())
+		}
+	}
+
+	// a new change starts over at depth 0
+	state.log = nil
+	w.SetCascadeLimit(DEFAULT_CASCADE_LIMIT)
+	w.SetBlock(0, 0, 0, testStone)
+	if len(state.log) != 29 {
+		t.Errorf("%d flips after raising the limit, want 29", len(state.log))
+	}
+}
+
+func TestNeighborDispatch(t *testing.T) {
+	w, _, _, state := newFlipColumn(8)
+	w.SetBlock(0, 0, 0, nil)
+	// changes made by listeners are queued, not dispatched from inside them
+	if state.nesting != 1 {
+		t.Errorf("listeners nested %d deep", state.nesting)
+	}
+	var want []Position
+	for y := 1; y < 8; y++ {
+		want = append(want, Position{0, y, 0})
+	}
+	if !reflect.DeepEqual(state.log, want) {
+		t.Errorf("flipped %v, want %v", state.log, want)
+	}
+	// each flipped block is told about its own change too
+	if !reflect.DeepEqual(state.self, want) {
+		t.Errorf("told %v about their own change, want %v", state.self, want)
+	}
+}
+
+// newSandWorld returns a world with two loaded chunks stacked on each
+// other and a scheduler for it.
+func newSandWorld() (*WorldChunked, *Scheduler) {
+	w := NewWorldChunked(NewBlockRegistry(), nil)
+	for y := 0; y < 2; y++ {
+		w.LoadChunk(Position{0, y, 0})
+	}
+	s := NewScheduler(&w, 1)
+	s.RandomTicks = 0
+	return &w, s
+}
+
+func TestFallingColumn(t *testing.T) {
+	w, s := newSandWorld()
+	sand := NewBlockFalling("sand", [6]string{})
+	gravel := NewBlockFalling("gravel", [6]string{})
+	water := NewBlockFluid("water", "", LAYER_TRANSLUCENT, 0, 7, 5)
+	w.SetBlock(0, 2, 0, testStone)
+	w.SetBlock(0, 3, 0, water)
+	w.SetBlock(0, 10, 0, testStone)
+	column := []Block{sand, gravel, sand, gravel}
+	for i, b := range column {
+		w.SetBlock(0, 11+i, 0, b)
+	}
+	w.SetBlock(0, 10, 0, nil)
+	for i := 0; i < 20*FALL_DELAY; i++ {
+		s.Tick()
+	}
+
+	// the column lands on the stone in order, through the water
+	for i, b := range column {
+		if got := w.GetBlock(0, 3+i, 0); got != b {
+			t.Errorf("y = %d: %v, want %s", 3+i, got, b.Name())
+		}
+	}
+	for y := 3 + len(column); y < 20; y++ {
+		if b, ok := w.GetBlock(0, y, 0).(*BlockFalling); ok {
+			t.Errorf("y = %d: %s left behind", y, b.Name())
+		}
+	}
+}
+
+func TestFallingUnloaded(t *testing.T) {
+	w, s := newSandWorld()
+	sand := NewBlockFalling("sand", [6]string{})
+	// the chunk below y = 0 isn't loaded, so the sand rests above it
+	w.SetBlock(0, 0, 0, testStone)
+	w.SetBlock(0, 1, 0, sand)
+	w.SetBlock(0, 0, 0, nil)
+	for i := 0; i < 4*FALL_DELAY; i++ {
+		s.Tick()
+	}
+	if w.GetBlock(0, 0, 0) != Block(sand) {
+		t.Errorf("sand didn't stop above the unloaded chunk: %v", w.GetBlock(0, 0, 0))
+	}
+	if s.Pending() != 0 {
+		t.Errorf("%d ticks left pending", s.Pending())
+	}
+}
@@ -0,0 +1,110 @@
+package world
+
+// PackedArray stores a fixed number of small unsigned integers, bits bits
+// each, packed into 64-bit words. Values never straddle two words.
+type PackedArray struct {
+	bits int
+	size int
+	data []uint64
+}
+
+func NewPackedArray(bits int, size int) PackedArray {
+	perWord := 64 / bits
+	return PackedArray{
+		bits: bits,
+		size: size,
+		data: make([]uint64, (size+perWord-1)/perWord),
+	}
+}
+
+func (a *PackedArray) Len() int {
+	return a.size
+}
+
+func (a *PackedArray) Get(i int) int {
+	perWord := 64 / a.bits
+	shift := uint((i % perWord) * a.bits)
+	return int((a.data[i/perWord] >> shift) & (1<<uint(a.bits) - 1))
+}
+
+func (a *PackedArray) Set(i int, v int) {
+	perWord := 64 / a.bits
+	shift := uint((i % perWord) * a.bits)
+	mask := uint64(1<<uint(a.bits)-1) << shift
+	a.data[i/perWord] = (a.data[i/perWord] &^ mask) | ((uint64(v) << shift) & mask)
+}
+
+// Resize returns a copy of the array using the given amount of bits per value.
+func (a *PackedArray) Resize(bits int) PackedArray {
+	b := NewPackedArray(bits, a.size)
+	for i := 0; i < a.size; i++ {
+		b.Set(i, a.Get(i))
+	}
+	return b
+}
+
+func bitsFor(n int) int {
+	bits := 1
+	for 1<<uint(bits) < n {
+		bits++
+	}
+	return bits
+}
+
+// paletteIndex returns the index of block in the chunk's palette, adding
+// it (and widening the index storage if need be) when it is not present.
+// Unused entries are dropped before the index storage is widened.
+func (c *Chunk) paletteIndex(block Block) int {
+	for i, b := range c.palette {
+		if b == block {
+			return i
+		}
+	}
+	if bitsFor(len(c.palette)+1) > c.indices.bits {
+		c.Compact()
+	}
+	c.palette = append(c.palette, block)
+	if bits := bitsFor(len(c.palette)); bits > c.indices.bits {
+		c.indices = c.indices.Resize(bits)
+	}
+	return len(c.palette) - 1
+}
+
+// Compact drops palette entries no longer referenced by any position and
+// shrinks the index storage to match.
+func (c *Chunk) Compact() {
+	if c.palette == nil {
+		return
+	}
+	used := make([]bool, len(c.palette))
+	for i := 0; i < c.indices.Len(); i++ {
+		used[c.indices.Get(i)] = true
+	}
+	remap := make([]int, len(c.palette))
+	palette := []Block{nil}
+	for i := 1; i < len(c.palette); i++ {
+		if used[i] && c.palette[i] != nil {
+			remap[i] = len(palette)
+			palette = append(palette, c.palette[i])
+		}
+	}
+	indices := NewPackedArray(bitsFor(len(palette)), CHUNK_VOLUME)
+	for i := 0; i < CHUNK_VOLUME; i++ {
+		indices.Set(i, remap[c.indices.Get(i)])
+	}
+	c.palette = palette
+	c.indices = indices
+}
+
+func (c *Chunk) recount() {
+	c.count = 0
+	for i := 0; i < c.indices.Len(); i++ {
+		if c.palette[c.indices.Get(i)] != nil {
+			c.count++
+		}
+	}
+	if c.count == 0 {
+		c.palette = nil
+		c.indices = PackedArray{}
+	}
+}
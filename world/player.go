@@ -0,0 +1,207 @@
+package world
+
+import (
+	"time"
+
+	"github.com/barnex/fmath"
+)
+
+const EYE_HEIGHT = 1.7
+
+// The player's bounding box is PLAYER_WIDTH wide and deep and PLAYER_HEIGHT
+// tall. Walking into a ledge up to STEP_HEIGHT high steps onto it.
+const (
+	PLAYER_WIDTH  = 0.6
+	PLAYER_HEIGHT = 1.8
+	STEP_HEIGHT   = 0.5
+)
+
+// Inside fluid, the player moves FLUID_DRAG times as fast, sinks at most
+// FLUID_SINK and rises at most FLUID_RISE blocks per update.
+const (
+	FLUID_DRAG = 0.5
+	FLUID_SINK = 0.03
+	FLUID_RISE = 0.06
+)
+
+// Player is the player's position, at the middle of their feet, and
+// motion.
+type Player struct {
+	Pos     Vec3
+	Gravity float32
+	Yaw     float32
+	Pitch   float32
+	// Swim is set while the player wants to swim up through fluid.
+	Swim bool
+	// OnGround is set while the player stands on a block.
+	OnGround bool
+}
+
+// BoundingBox returns the box the player takes up in the world.
+func (player Player) BoundingBox() BoundingBox {
+	return BoundingBox{
+		Vec3{player.Pos[0] - PLAYER_WIDTH/2, player.Pos[1], player.Pos[2] - PLAYER_WIDTH/2},
+		Vec3{player.Pos[0] + PLAYER_WIDTH/2, player.Pos[1] + PLAYER_HEIGHT, player.Pos[2] + PLAYER_WIDTH/2},
+	}
+}
+
+// isSolid reports whether the player collides with b. Fluids are swum
+// through.
+func isSolid(b Block) bool {
+	if b == nil {
+		return false
+	}
+	_, fluid := b.(*BlockFluid)
+	return !fluid
+}
+
+// collisionBoxes returns the bounding boxes, in world coordinates, of the
+// blocks the player collides with in area.
+func collisionBoxes(w BlockAccess, area BoundingBox) []BoundingBox {
+	var boxes []BoundingBox
+	x0, x1 := int(fmath.Floor(area.Min[0])), int(fmath.Floor(area.Max[0]))
+	y0, y1 := int(fmath.Floor(area.Min[1])), int(fmath.Floor(area.Max[1]))
+	z0, z1 := int(fmath.Floor(area.Min[2])), int(fmath.Floor(area.Max[2]))
+	// boxes may stick out of the bottom of the block above
+	for y := y0 - 1; y <= y1; y++ {
+		for z := z0; z <= z1; z++ {
+			for x := x0; x <= x1; x++ {
+				if b := w.GetBlock(x, y, z); isSolid(b) {
+					boxes = append(boxes, b.GetBoundingBox().Translate(Vec3{float32(x), float32(y), float32(z)}))
+				}
+			}
+		}
+	}
+	return boxes
+}
+
+// sweep returns how far box can move by up to v without running into
+// boxes. It moves along one axis at a time, vertically first, so that it
+// slides along what it hits.
+func sweep(box BoundingBox, boxes []BoundingBox, v Vec3) Vec3 {
+	for _, axis := range [3]int{1, 0, 2} {
+		for _, b := range boxes {
+			v[axis] = box.Clip(b, axis, v[axis])
+		}
+		var step Vec3
+		step[axis] = v[axis]
+		box = box.Translate(step)
+	}
+	return v
+}
+
+// InFluid reports whether the player's feet or body are in fluid.
+func (player Player) InFluid(w BlockAccess) bool {
+	p := player.Pos.BlockPos()
+	for i := 0; i < 2; i++ {
+		if _, ok := w.GetBlock(p.X, p.Y+i, p.Z).(*BlockFluid); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// LookDirection returns the unit vector the player is looking along.
+func (player Player) LookDirection() Vec3 {
+	return Vec3{
+		fmath.Sin(player.Yaw) * fmath.Cos(player.Pitch),
+		-fmath.Sin(player.Pitch),
+		-fmath.Cos(player.Yaw) * fmath.Cos(player.Pitch),
+	}
+}
+
+func (player Player) GetHoverCoords(w BlockAccess) (Position, bool) {
+	stepX := -fmath.Sin(-player.Yaw) * fmath.Cos(player.Pitch) * 0.2
+	stepY := -fmath.Sin(player.Pitch) * 0.2
+	stepZ := -fmath.Cos(-player.Yaw) * fmath.Cos(player.Pitch) * 0.2
+	bX := player.Pos[0]
+	bY := player.Pos[1] + EYE_HEIGHT
+	bZ := player.Pos[2]
+	for stepCount := 100; stepCount > 0; stepCount-- {
+		if p := (Vec3{bX, bY, bZ}).BlockPos(); isSolid(w.GetBlock(p.X, p.Y, p.Z)) {
+			return p, true
+		} else {
+			bX += stepX
+			bY += stepY
+			bZ += stepZ
+		}
+	}
+	return Position{}, false
+}
+
+// GetPlaceCoords returns the position in front of the block the player is
+// looking at, which is empty or holds fluid.
+func (player Player) GetPlaceCoords(w BlockAccess) (Position, bool) {
+	stepX := -fmath.Sin(-player.Yaw) * fmath.Cos(player.Pitch) * 0.2
+	stepY := -fmath.Sin(player.Pitch) * 0.2
+	stepZ := -fmath.Cos(-player.Yaw) * fmath.Cos(player.Pitch) * 0.2
+	bX := player.Pos[0]
+	bY := player.Pos[1] + EYE_HEIGHT
+	bZ := player.Pos[2]
+	for stepCount := 100; stepCount > 0; stepCount-- {
+		if p := (Vec3{bX, bY, bZ}).BlockPos(); isSolid(w.GetBlock(p.X, p.Y, p.Z)) {
+			return Vec3{bX - stepX, bY - stepY, bZ - stepZ}.BlockPos(), true
+		} else {
+			bX += stepX
+			bY += stepY
+			bZ += stepZ
+		}
+	}
+	return Position{}, false
+}
+
+func (player *Player) Jump() {
+	player.Gravity = 0.3
+}
+
+// Move advances the player's physics by nanoTime, walking movementX
+// forward and movementZ to the side (per 16 milliseconds). The player's
+// bounding box is swept against those of the blocks around it.
+func (player *Player) Move(w BlockAccess, nanoTime time.Duration, movementX float32, movementZ float32) {
+	movementLX := movementX * float32(nanoTime) / (16 * 1000000)
+	movementLZ := movementZ * float32(nanoTime) / (16 * 1000000)
+	gravityLD := 0.5 * float32(nanoTime) / (1000 * 1000000)
+	inFluid := player.InFluid(w)
+	if inFluid {
+		movementLX *= FLUID_DRAG
+		movementLZ *= FLUID_DRAG
+		gravityLD *= FLUID_DRAG
+	}
+
+	// fall
+	player.Gravity -= gravityLD
+	if inFluid {
+		if player.Swim || player.Gravity > FLUID_RISE {
+			player.Gravity = FLUID_RISE
+		} else if player.Gravity < -FLUID_SINK {
+			player.Gravity = -FLUID_SINK
+		}
+	}
+
+	v := Vec3{
+		-fmath.Sin(-player.Yaw)*movementLX + fmath.Cos(-player.Yaw)*movementLZ,
+		player.Gravity,
+		-fmath.Cos(-player.Yaw)*movementLX - fmath.Sin(-player.Yaw)*movementLZ,
+	}
+	box := player.BoundingBox()
+	boxes := collisionBoxes(w, box.Expand(v).Expand(Vec3{0, STEP_HEIGHT, 0}))
+	moved := sweep(box, boxes, v)
+	landed := v[1] < 0 && moved[1] > v[1]
+	if moved[1] != v[1] {
+		player.Gravity = 0
+	}
+
+	// walking into a low ledge, try stepping up, across and back down
+	if (landed || player.OnGround) && (moved[0] != v[0] || moved[2] != v[2]) {
+		up := sweep(box, boxes, Vec3{0, STEP_HEIGHT, 0})
+		across := sweep(box.Translate(up), boxes, Vec3{v[0], 0, v[2]})
+		down := sweep(box.Translate(up).Translate(across), boxes, Vec3{0, -up[1], 0})
+		if across[0]*across[0]+across[2]*across[2] > moved[0]*moved[0]+moved[2]*moved[2] {
+			moved = up.Translate(across).Translate(down)
+			landed = true
+		}
+	}
+
+	player.Pos = player.Pos.Translate(moved)
+	player.OnGround = landed
+}
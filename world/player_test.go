@@ -0,0 +1,125 @@
+package world
+
+import (
+	"testing"
+	"time"
+)
+
+var testStone = NewBlockSimple("stone", [6]string{})
+
+// newFloorWorld returns a world with a stone floor at y=0, from -16 to 31
+// on x and z.
+func newFloorWorld() *WorldChunked {
+	w := NewWorldChunked(NewBlockRegistry(), &GeneratorVoid{})
+	for x := -16; x < 32; x++ {
+		for z := -16; z < 32; z++ {
+			w.SetBlock(x, 0, z, testStone)
+		}
+	}
+	return &w
+}
+
+// walk moves the player for n updates of 16 milliseconds.
+func walk(p *Player, w BlockAccess, n int, forward float32) {
+	for i := 0; i < n; i++ {
+		p.Move(w, 16*time.Millisecond, forward, 0)
+	}
+}
+
+func near(a float32, b float32) bool {
+	return a-b < 1e-4 && b-a < 1e-4
+}
+
+func TestPlayerLands(t *testing.T) {
+	w := newFloorWorld()
+	p := &Player{Pos: Vec3{4.5, 5, 4.5}}
+	walk(p, w, 200, 0)
+	if p.Pos[1] != 1 || !p.OnGround || p.Gravity != 0 {
+		t.Errorf("at %v, on ground %v, gravity %v", p.Pos, p.OnGround, p.Gravity)
+	}
+}
+
+func TestPlayerStoppedByWall(t *testing.T) {
+	w := newFloorWorld()
+	for x := 0; x < 10; x++ {
+		w.SetBlock(x, 1, 2, testStone)
+		w.SetBlock(x, 2, 2, testStone)
+	}
+	// yaw 0 walks towards -z
+	p := &Player{Pos: Vec3{4.5, 1, 4.5}}
+	walk(p, w, 100, 0.12)
+	if !near(p.Pos[2], 3+PLAYER_WIDTH/2) || p.Pos[1] != 1 {
+		t.Fatalf("at %v, want against the wall at z=%v", p.Pos, 3+PLAYER_WIDTH/2)
+	}
+	// straddling two blocks of the wall
+	p.Pos[0] = 5
+	walk(p, w, 20, 0.12)
+	if !near(p.Pos[2], 3+PLAYER_WIDTH/2) {
+		t.Fatalf("at %v, went into the wall between two blocks", p.Pos)
+	}
+	// walking at an angle slides along the wall
+	p.Yaw = 0.5
+	walk(p, w, 20, 0.12)
+	if p.Pos[0] == 5 || !near(p.Pos[2], 3+PLAYER_WIDTH/2) {
+		t.Errorf("at %v, want slid along the wall", p.Pos)
+	}
+}
+
+func TestPlayerStepsOntoSlab(t *testing.T) {
+	w := newFloorWorld()
+	slab := NewBlockSlab("slab", [6]string{})
+	for x := 0; x < 10; x++ {
+		w.SetBlock(x, 1, 2, slab)
+		w.SetBlock(x, 1, -4, testStone)
+	}
+	p := &Player{Pos: Vec3{4.5, 1, 4.5}}
+	walk(p, w, 5, 0)
+	walk(p, w, 15, 0.12)
+	if p.Pos[1] != 1.5 || !near(p.Pos[2], 2.7) {
+		t.Fatalf("at %v, want on the slab", p.Pos)
+	}
+	// off the slab, then into the full block, which is too high to step
+	walk(p, w, 100, 0.12)
+	if p.Pos[1] != 1 || !near(p.Pos[2], -3+PLAYER_WIDTH/2) {
+		t.Errorf("at %v, want against the block at z=%v", p.Pos, -3+PLAYER_WIDTH/2)
+	}
+}
+
+func TestPlayerSwims(t *testing.T) {
+	w := newFloorWorld()
+	water := NewBlockFluid("water", "water", LAYER_TRANSLUCENT, 0, 7, 5)
+	for y := 1; y < 6; y++ {
+		w.SetBlock(4, y, 4, water)
+	}
+	p := &Player{Pos: Vec3{4.5, 5, 4.5}}
+	walk(p, w, 2, 0)
+	if p.Gravity < -FLUID_SINK {
+		t.Fatalf("sinking at %v, faster than %v", p.Gravity, FLUID_SINK)
+	}
+	walk(p, w, 300, 0)
+	if p.Pos[1] != 1 {
+		t.Fatalf("at %v, want at the bottom", p.Pos)
+	}
+	p.Swim = true
+	walk(p, w, 10, 0)
+	if p.Pos[1] < 1.5 {
+		t.Errorf("at %v, want swum up", p.Pos)
+	}
+}
+
+func TestPlayerTargetsAtNegativeCoords(t *testing.T) {
+	w := newFloorWorld()
+	for x := -4; x < 0; x++ {
+		for z := -4; z < 0; z++ {
+			w.SetBlock(x, 2, z, testStone)
+		}
+	}
+	// looking straight down from above the block at -3, 2, -3
+	p := Player{Pos: Vec3{-2.5, 3, -2.5}, Pitch: 1.5707}
+	if pos, ok := p.GetHoverCoords(w); !ok || pos != (Position{-3, 2, -3}) {
+		t.Errorf("hovering over %v, %v, want -3, 2, -3", pos, ok)
+	}
+	if pos, ok := p.GetPlaceCoords(w); !ok || pos != (Position{-3, 3, -3}) {
+		t.Errorf("placing at %v, %v, want -3, 3, -3", pos, ok)
+	}
+}
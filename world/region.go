@@ -0,0 +1,697 @@
+package world
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// On-disk layout of a world directory:
+//
+//	level.dat       - gzip: magic, version, seed, generator name and options
+//	r.X.Y.Z.rgn     - gzip: magic, version, chunk count, then per chunk its
+//	                  offset inside the region and its block data, then the
+//	                  decoration writes pending for chunks not generated yet
+//
+// A region holds REGION_SIZE^3 chunks. Each chunk is stored with its own
+// palette of block names and states and bit-packed indices into it, so saves
+// do not depend on the ids handed out by BlockRegistry. Version 2 saves did
+// not store block states; saves before version 4 were always generated by
+// the simplex generator and did not keep pending decoration writes.
+//
+// Version 1 saves stored raw block ids along with a global name to id
+// palette in level.dat; they are migrated to the current BlockRegistry on
+// load.
+//
+// Worlds unload regions into their save directory as a whole, in the same
+// format, so a region is either resident or saved there.
+
+const (
+	REGION_SIZE  = 8
+	REGION_SHIFT = 3
+	REGION_MASK  = REGION_SIZE - 1
+
+	SAVE_VERSION = 5
+
+	chunkStorageEmpty    = 0
+	chunkStorageRaw      = 1 // version 1 only
+	chunkStoragePaletted = 2
+)
+
+var (
+	levelMagic  = [4]byte{'R', 'D', 'L', 'V'}
+	regionMagic = [4]byte{'R', 'D', 'R', 'G'}
+)
+
+var ErrBadSave = errors.New("invalid save data")
+
+func regionPos(p Position) Position {
+	return Position{p.X >> REGION_SHIFT, p.Y >> REGION_SHIFT, p.Z >> REGION_SHIFT}
+}
+
+func regionFileName(p Position) string {
+	return fmt.Sprintf("r.%d.%d.%d.rgn", p.X, p.Y, p.Z)
+}
+
+func writeString(wr io.Writer, s string) error {
+	if err := binary.Write(wr, binary.BigEndian, uint16(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(wr, s)
+	return err
+}
+
+func readString(rd io.Reader) (string, error) {
+	var l uint16
+	if err := binary.Read(rd, binary.BigEndian, &l); err != nil {
+		return "", err
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(rd, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func writeHeader(wr io.Writer, magic [4]byte) error {
+	if _, err := wr.Write(magic[:]); err != nil {
+		return err
+	}
+	return binary.Write(wr, binary.BigEndian, uint16(SAVE_VERSION))
+}
+
+func readHeader(rd io.Reader, magic [4]byte) (int, error) {
+	var m [4]byte
+	var version uint16
+	if _, err := io.ReadFull(rd, m[:]); err != nil {
+		return 0, err
+	}
+	if m != magic {
+		return 0, ErrBadSave
+	}
+	if err := binary.Read(rd, binary.BigEndian, &version); err != nil {
+		return 0, err
+	}
+	if version == 0 || version > SAVE_VERSION {
+		return 0, fmt.Errorf("unsupported save version %d", version)
+	}
+	return int(version), nil
+}
+
+// writeGzipFile hands a compressed writer to f, then replaces the named
+// file with what it wrote. The data goes to a temporary file first, so that
+// a failed save leaves the old file in place.
+func writeGzipFile(name string, f func(io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	bw := bufio.NewWriter(gz)
+	if err := f(bw); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	// temporary files are only readable by their owner
+	if err := file.Chmod(0644); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), name)
+}
+
+func readGzipFile(name string, f func(io.Reader) error) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	return f(bufio.NewReader(gz))
+}
+
+// SaveWorld writes every resident chunk of w, along with its generator
+// settings, into the directory dir. A world without a generator is saved
+// as a void one, which generates nothing either. Regions unloaded from w
+// are copied over from its save directory, if dir is another one.
+func SaveWorld(w *WorldChunked, dir string) error {
+	// saving compacts the chunks, so it needs the world to itself
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	dir = filepath.Clean(dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeLevel(w, dir); err != nil {
+		return err
+	}
+	if w.dir != "" && dir != w.dir {
+		if err := w.copyStored(dir); err != nil {
+			return err
+		}
+	}
+
+	regions, pending := w.regionChunks()
+	for rp, chunks := range regions {
+		if err := saveRegion(w, dir, rp, chunks, pending[rp]); err != nil {
+			return err
+		}
+	}
+	if dir == w.dir {
+		w.dirty = make(map[Position]bool)
+	}
+	return nil
+}
+
+func writeLevel(w *WorldChunked, dir string) error {
+	generator := w.generator
+	if generator == nil {
+		generator = &GeneratorVoid{}
+	}
+	return writeGzipFile(filepath.Join(dir, "level.dat"), func(wr io.Writer) error {
+		if err := writeHeader(wr, levelMagic); err != nil {
+			return err
+		}
+		if err := binary.Write(wr, binary.BigEndian, generator.Seed()); err != nil {
+			return err
+		}
+		if err := writeString(wr, generator.Name()); err != nil {
+			return err
+		}
+		return writeString(wr, generator.Options())
+	})
+}
+
+// regionChunks groups the resident chunks of w, and the chunks with
+// pending decoration writes, by region. Regions holding only pending
+// writes have no resident chunks listed.
+func (w *WorldChunked) regionChunks() (map[Position][]Position, map[Position][]Position) {
+	regions := make(map[Position][]Position)
+	pending := make(map[Position][]Position)
+	for p := range w.chunks {
+		rp := regionPos(p)
+		regions[rp] = append(regions[rp], p)
+	}
+	for p := range w.pending {
+		rp := regionPos(p)
+		pending[rp] = append(pending[rp], p)
+		if _, ok := regions[rp]; !ok {
+			regions[rp] = nil
+		}
+	}
+	return regions, pending
+}
+
+func saveRegion(w *WorldChunked, dir string, rp Position, chunks []Position, pending []Position) error {
+	return writeGzipFile(filepath.Join(dir, regionFileName(rp)), func(wr io.Writer) error {
+		if err := writeRegion(wr, w, chunks); err != nil {
+			return err
+		}
+		return writePending(wr, w, pending)
+	})
+}
+
+// copyStored copies the regions unloaded from w into the directory dir.
+func (w *WorldChunked) copyStored(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for rp := range w.stored {
+		name := regionFileName(rp)
+		err := readGzipFile(filepath.Join(w.dir, name), func(rd io.Reader) error {
+			return writeGzipFile(filepath.Join(dir, name), func(wr io.Writer) error {
+				_, err := io.Copy(wr, rd)
+				return err
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetSaveDir sets the directory w unloads regions into. LoadWorld sets it
+// to the directory the world was loaded from. Regions already unloaded are
+// copied over, and the resident ones are saved there when unloaded, even
+// if they did not change.
+func (w *WorldChunked) SetSaveDir(dir string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	dir = filepath.Clean(dir)
+	if dir == w.dir {
+		return nil
+	}
+	if w.dir != "" {
+		if err := w.copyStored(dir); err != nil {
+			return err
+		}
+	}
+	w.dir = dir
+	regions, _ := w.regionChunks()
+	for rp := range regions {
+		w.dirty[rp] = true
+	}
+	return nil
+}
+
+// UnloadFar unloads the regions of chunks none of which are within radius
+// chunks of the given block position, as in InViewDistance, along with
+// their pending decoration writes. Regions changed since they were loaded
+// are saved into the save directory first. It returns the number of chunks
+// unloaded and the first error met saving a region, or else loading one
+// back since the last call. Without a save directory, nothing is unloaded.
+func (w *WorldChunked) UnloadFar(x int, y int, z int, radius int) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.dir == "" {
+		return 0, nil
+	}
+	center := chunkPos(x, y, z)
+	regions, pending := w.regionChunks()
+	near := func(chunks []Position) bool {
+		for _, p := range chunks {
+			if InViewDistance(center, p, radius) {
+				return true
+			}
+		}
+		return false
+	}
+
+	unloaded := 0
+	for rp, chunks := range regions {
+		if near(chunks) || near(pending[rp]) {
+			continue
+		}
+		if w.dirty[rp] {
+			if err := w.saveUnloaded(rp, chunks, pending[rp]); err != nil {
+				return unloaded, err
+			}
+		}
+		for _, p := range chunks {
+			delete(w.chunks, p)
+		}
+		for _, p := range pending[rp] {
+			delete(w.pending, p)
+		}
+		delete(w.dirty, rp)
+		w.stored[rp] = true
+		unloaded += len(chunks)
+	}
+	err := w.loadErr
+	w.loadErr = nil
+	return unloaded, err
+}
+
+// saveUnloaded saves the region rp into the save directory, along with
+// level.dat if the world was never saved there.
+func (w *WorldChunked) saveUnloaded(rp Position, chunks []Position, pending []Position) error {
+	if err := os.MkdirAll(w.dir, 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(w.dir, "level.dat")); errors.Is(err, os.ErrNotExist) {
+		if err := writeLevel(w, w.dir); err != nil {
+			return err
+		}
+	}
+	return saveRegion(w, w.dir, rp, chunks, pending)
+}
+
+// restoreRegion loads the unloaded region rp back from the save directory,
+// lighting its chunks. If that fails, the region is left unloaded and the
+// error kept for UnloadFar to report.
+func (w *WorldChunked) restoreRegion(rp Position) bool {
+	r := WorldChunked{
+		chunks:   make(map[Position]*Chunk),
+		blockReg: w.blockReg,
+		pending:  make(map[Position][]pendingWrite),
+		dirty:    make(map[Position]bool),
+	}
+	err := readGzipFile(filepath.Join(w.dir, regionFileName(rp)), func(rd io.Reader) error {
+		return readRegion(rd, &r, rp, nil)
+	})
+	if err != nil {
+		if w.loadErr == nil {
+			w.loadErr = fmt.Errorf("%s: %w", regionFileName(rp), err)
+		}
+		return false
+	}
+	delete(w.stored, rp)
+	delete(w.dirty, rp)
+	if r.dirty[rp] {
+		w.dirty[rp] = true
+	}
+	chunks := make([]Position, 0, len(r.chunks))
+	for p, c := range r.chunks {
+		w.chunks[p] = c
+		chunks = append(chunks, p)
+		w.events = append(w.events, worldEvent{pos: p, load: true})
+	}
+	for p, writes := range r.pending {
+		w.pending[p] = writes
+	}
+	w.lightChunks(chunks)
+	return true
+}
+
+func writeRegion(wr io.Writer, w *WorldChunked, chunks []Position) error {
+	if err := writeHeader(wr, regionMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(wr, binary.BigEndian, uint16(len(chunks))); err != nil {
+		return err
+	}
+	for _, p := range chunks {
+		c := w.chunks[p]
+		offset := [3]byte{byte(p.X & REGION_MASK), byte(p.Y & REGION_MASK), byte(p.Z & REGION_MASK)}
+		if _, err := wr.Write(offset[:]); err != nil {
+			return err
+		}
+		if c.IsEmpty() {
+			if _, err := wr.Write([]byte{chunkStorageEmpty}); err != nil {
+				return err
+			}
+			continue
+		}
+		c.Compact()
+		if _, err := wr.Write([]byte{chunkStoragePaletted}); err != nil {
+			return err
+		}
+		if err := binary.Write(wr, binary.BigEndian, uint16(len(c.palette))); err != nil {
+			return err
+		}
+		for _, b := range c.palette {
+			if err := writeBlock(wr, b); err != nil {
+				return err
+			}
+		}
+		if _, err := wr.Write([]byte{byte(c.indices.bits)}); err != nil {
+			return err
+		}
+		if err := binary.Write(wr, binary.BigEndian, c.indices.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeBlock(wr io.Writer, b Block) error {
+	name := ""
+	var state BlockState
+	if b != nil {
+		name = b.Name()
+		state = b.State()
+	}
+	if err := writeString(wr, name); err != nil {
+		return err
+	}
+	return binary.Write(wr, binary.BigEndian, uint16(state))
+}
+
+func readBlock(rd io.Reader, blockReg BlockRegistry) (Block, error) {
+	name, err := readString(rd)
+	if err != nil {
+		return nil, err
+	}
+	var state uint16
+	if err := binary.Read(rd, binary.BigEndian, &state); err != nil {
+		return nil, err
+	}
+	if b := blockReg.ByName(name); b != nil {
+		return b.WithState(BlockState(state)), nil
+	}
+	return nil, nil
+}
+
+func writePending(wr io.Writer, w *WorldChunked, chunks []Position) error {
+	if err := binary.Write(wr, binary.BigEndian, uint16(len(chunks))); err != nil {
+		return err
+	}
+	for _, p := range chunks {
+		writes := w.pending[p]
+		offset := [3]byte{byte(p.X & REGION_MASK), byte(p.Y & REGION_MASK), byte(p.Z & REGION_MASK)}
+		if _, err := wr.Write(offset[:]); err != nil {
+			return err
+		}
+		if err := binary.Write(wr, binary.BigEndian, uint32(len(writes))); err != nil {
+			return err
+		}
+		for _, pw := range writes {
+			if err := binary.Write(wr, binary.BigEndian, uint16(pw.index)); err != nil {
+				return err
+			}
+			if err := writeBlock(wr, pw.block); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readPending(rd io.Reader, w *WorldChunked, rp Position) error {
+	var count uint16
+	if err := binary.Read(rd, binary.BigEndian, &count); err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		var offset [3]byte
+		if _, err := io.ReadFull(rd, offset[:]); err != nil {
+			return err
+		}
+		p := Position{
+			rp.X<<REGION_SHIFT + int(offset[0]&REGION_MASK),
+			rp.Y<<REGION_SHIFT + int(offset[1]&REGION_MASK),
+			rp.Z<<REGION_SHIFT + int(offset[2]&REGION_MASK),
+		}
+		var writes uint32
+		if err := binary.Read(rd, binary.BigEndian, &writes); err != nil {
+			return err
+		}
+		for j := 0; j < int(writes); j++ {
+			var index uint16
+			if err := binary.Read(rd, binary.BigEndian, &index); err != nil {
+				return err
+			}
+			if index >= CHUNK_VOLUME {
+				return ErrBadSave
+			}
+			b, err := readBlock(rd, w.blockReg)
+			if err != nil {
+				return err
+			}
+			w.pending[p] = append(w.pending[p], pendingWrite{int(index), b})
+		}
+	}
+	return nil
+}
+
+// LoadWorld reads a world previously written by SaveWorld. Blocks are
+// resolved by name through blockReg; names it does not know become air.
+func LoadWorld(dir string, blockReg BlockRegistry) (WorldChunked, error) {
+	// the generator is set once level.dat is read
+	w := NewWorldChunked(blockReg, nil)
+	var version int
+	remap := make(map[int16]Block)
+
+	err := readGzipFile(filepath.Join(dir, "level.dat"), func(rd io.Reader) error {
+		var err error
+		if version, err = readHeader(rd, levelMagic); err != nil {
+			return err
+		}
+		var seed int64
+		if err := binary.Read(rd, binary.BigEndian, &seed); err != nil {
+			return err
+		}
+		if version == 1 {
+			if err := readLegacyPalette(rd, blockReg, remap); err != nil {
+				return err
+			}
+		}
+		name, options := "simplex", ""
+		if version >= 4 {
+			if name, err = readString(rd); err != nil {
+				return err
+			}
+			if options, err = readString(rd); err != nil {
+				return err
+			}
+		}
+		w.generator, err = NewGenerator(name, seed, options, blockReg)
+		return err
+	})
+	if err != nil {
+		return w, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "r.*.rgn"))
+	if err != nil {
+		return w, err
+	}
+	for _, name := range files {
+		var rp Position
+		if _, err := fmt.Sscanf(filepath.Base(name), "r.%d.%d.%d.rgn", &rp.X, &rp.Y, &rp.Z); err != nil {
+			return w, fmt.Errorf("%s: %w", name, ErrBadSave)
+		}
+		err := readGzipFile(name, func(rd io.Reader) error {
+			return readRegion(rd, &w, rp, remap)
+		})
+		if err != nil {
+			return w, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	w.lightAll()
+	// nothing is listening yet
+	w.events = nil
+	w.dir = filepath.Clean(dir)
+	return w, nil
+}
+
+// readLegacyPalette reads the global name to id palette of a version 1
+// save, mapping each saved id to the block currently registered under
+// that name.
+func readLegacyPalette(rd io.Reader, blockReg BlockRegistry, remap map[int16]Block) error {
+	var count uint16
+	if err := binary.Read(rd, binary.BigEndian, &count); err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		name, err := readString(rd)
+		if err != nil {
+			return err
+		}
+		var id int16
+		if err := binary.Read(rd, binary.BigEndian, &id); err != nil {
+			return err
+		}
+		remap[id] = blockReg.ByName(name)
+	}
+	return nil
+}
+
+func readRegion(rd io.Reader, w *WorldChunked, rp Position, remap map[int16]Block) error {
+	version, err := readHeader(rd, regionMagic)
+	if err != nil {
+		return err
+	}
+	var count uint16
+	if err := binary.Read(rd, binary.BigEndian, &count); err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		var hdr [4]byte
+		if _, err := io.ReadFull(rd, hdr[:]); err != nil {
+			return err
+		}
+		p := Position{
+			rp.X<<REGION_SHIFT + int(hdr[0]&REGION_MASK),
+			rp.Y<<REGION_SHIFT + int(hdr[1]&REGION_MASK),
+			rp.Z<<REGION_SHIFT + int(hdr[2]&REGION_MASK),
+		}
+		c := &Chunk{}
+		w.chunks[p] = c
+		switch hdr[3] {
+		case chunkStorageEmpty:
+		case chunkStorageRaw:
+			err = readChunkRaw(rd, c, remap)
+		case chunkStoragePaletted:
+			err = readChunkPaletted(rd, c, w.blockReg, version)
+		default:
+			err = ErrBadSave
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// older regions are saved again in the current format when unloaded
+	if version < SAVE_VERSION {
+		w.dirty[rp] = true
+	}
+	if version >= 5 {
+		return readPending(rd, w, rp)
+	}
+	return nil
+}
+
+func readChunkRaw(rd io.Reader, c *Chunk, remap map[int16]Block) error {
+	blocks := make([]int16, CHUNK_VOLUME)
+	if err := binary.Read(rd, binary.BigEndian, blocks); err != nil {
+		return err
+	}
+	for i, id := range blocks {
+		if id != 0 {
+			c.set(i, remap[id])
+		}
+	}
+	return nil
+}
+
+func readChunkPaletted(rd io.Reader, c *Chunk, blockReg BlockRegistry, version int) error {
+	var count uint16
+	if err := binary.Read(rd, binary.BigEndian, &count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrBadSave
+	}
+	palette := make([]Block, count)
+	for i := range palette {
+		if version < 3 {
+			name, err := readString(rd)
+			if err != nil {
+				return err
+			}
+			palette[i] = blockReg.ByName(name)
+			continue
+		}
+		b, err := readBlock(rd, blockReg)
+		if err != nil {
+			return err
+		}
+		palette[i] = b
+	}
+	// index 0 is air, which chunks rely on when clearing blocks
+	if palette[0] != nil {
+		return ErrBadSave
+	}
+	var bits [1]byte
+	if _, err := io.ReadFull(rd, bits[:]); err != nil {
+		return err
+	}
+	if bits[0] == 0 || bits[0] > 16 || 1<<bits[0] < int(count) {
+		return ErrBadSave
+	}
+	indices := NewPackedArray(int(bits[0]), CHUNK_VOLUME)
+	if err := binary.Read(rd, binary.BigEndian, indices.data); err != nil {
+		return err
+	}
+	for i := 0; i < CHUNK_VOLUME; i++ {
+		if indices.Get(i) >= len(palette) {
+			return ErrBadSave
+		}
+	}
+	c.palette = palette
+	c.indices = indices
+	c.recount()
+	return nil
+}
@@ -0,0 +1,494 @@
+package world
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestRegistry registers the blocks the generators use, in the given
+// order of names.
+func newTestRegistry(names []string) BlockRegistry {
+	br := NewBlockRegistry()
+	for _, n := range names {
+		switch n {
+		case "stone_slab":
+			br.Register(NewBlockSlab(n, [6]string{}))
+		case "log":
+			br.Register(NewBlockLog(n, "", ""))
+		case "leaves":
+			br.Register(NewBlockLayered(n, [6]string{}, LAYER_CUTOUT))
+		case "water":
+			br.Register(NewBlockFluid(n, "", LAYER_TRANSLUCENT, 0, 7, 5))
+		default:
+			br.Register(NewBlockSimple(n, [6]string{}))
+		}
+	}
+	return br
+}
+
+var testBlockNames = []string{
+	"grass", "dirt", "stone", "gold_block", "stone_slab", "sand", "sandstone", "snow",
+	"coal_ore", "iron_ore", "gold_ore", "log", "leaves", "water",
+}
+
+func reversed(names []string) []string {
+	r := make([]string, len(names))
+	for i, n := range names {
+		r[len(names)-1-i] = n
+	}
+	return r
+}
+
+// sameBlock reports whether a and b are the same state of the same block,
+// possibly from different registries.
+func sameBlock(a Block, b Block) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Name() == b.Name() && a.State() == b.State()
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	br := newTestRegistry(testBlockNames)
+	w := NewWorldChunked(br, NewGeneratorSimplex(br, 7))
+	w.LoadAround(0, 70, 0, 4, 100000)
+	if len(w.pending) == 0 {
+		t.Fatal("no pending decoration writes to save")
+	}
+	slab := WithProperty(br.ByName("stone_slab"), "half", "top")
+	w.SetBlock(-40, 300, -7, slab)
+	w.SetBlock(-41, 300, -7, WithProperty(br.ByName("log"), "axis", "x"))
+	w.SetBlock(-42, 300, -7, br.ByName("water").(*BlockFluid).flowing(3, false))
+
+	dir := t.TempDir()
+	if err := SaveWorld(&w, dir); err != nil {
+		t.Fatal(err)
+	}
+	// load with the blocks registered under other ids
+	loaded, err := LoadWorld(dir, newTestRegistry(reversed(testBlockNames)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.generator.Name() != "simplex" || loaded.generator.Seed() != 7 {
+		t.Errorf("generator %s, seed %d", loaded.generator.Name(), loaded.generator.Seed())
+	}
+	if len(loaded.chunks) != len(w.chunks) {
+		t.Fatalf("%d chunks loaded, %d saved", len(loaded.chunks), len(w.chunks))
+	}
+	for p := range w.chunks {
+		for i := 0; i < CHUNK_VOLUME; i++ {
+			x, y, z := p.X<<CHUNK_SHIFT+i&CHUNK_MASK, p.Y<<CHUNK_SHIFT+i>>(2*CHUNK_SHIFT), p.Z<<CHUNK_SHIFT+(i>>CHUNK_SHIFT)&CHUNK_MASK
+			if a, b := w.GetBlock(x, y, z), loaded.GetBlock(x, y, z); !sameBlock(a, b) {
+				t.Fatalf("block at %d, %d, %d: loaded %v, saved %v", x, y, z, b, a)
+			}
+		}
+	}
+	if b := loaded.GetBlock(-40, 300, -7); b == nil || GetProperty(b, "half") != "top" {
+		t.Errorf("top slab loaded as %v", b)
+	}
+
+	if len(loaded.pending) != len(w.pending) {
+		t.Fatalf("%d chunks with pending writes loaded, %d saved", len(loaded.pending), len(w.pending))
+	}
+	for p, writes := range w.pending {
+		got := loaded.pending[p]
+		if len(got) != len(writes) {
+			t.Fatalf("chunk %v: %d pending writes loaded, %d saved", p, len(got), len(writes))
+		}
+		for i := range writes {
+			if got[i].index != writes[i].index || !sameBlock(got[i].block, writes[i].block) {
+				t.Fatalf("chunk %v: pending write %d differs", p, i)
+			}
+		}
+	}
+}
+
+func TestWriteGzipFileKeepsOldFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "level.dat")
+	write := func(s string) func(io.Writer) error {
+		return func(wr io.Writer) error {
+			_, err := io.WriteString(wr, s)
+			return err
+		}
+	}
+	if err := writeGzipFile(name, write("old")); err != nil {
+		t.Fatal(err)
+	}
+	failed := errors.New("failed")
+	err := writeGzipFile(name, func(wr io.Writer) error {
+		io.WriteString(wr, "partial")
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("got error %v, want %v", err, failed)
+	}
+	var got []byte
+	err = readGzipFile(name, func(rd io.Reader) error {
+		var err error
+		got, err = io.ReadAll(rd)
+		return err
+	})
+	if err != nil || string(got) != "old" {
+		t.Errorf("read %q, %v; want the old contents", got, err)
+	}
+	if files, _ := os.ReadDir(filepath.Dir(name)); len(files) != 1 {
+		t.Errorf("%d files left behind, want 1", len(files))
+	}
+}
+
+// writeVersionHeader writes a header as SaveWorld did in the given version.
+func writeVersionHeader(wr io.Writer, magic [4]byte, version uint16) error {
+	if _, err := wr.Write(magic[:]); err != nil {
+		return err
+	}
+	return binary.Write(wr, binary.BigEndian, version)
+}
+
+// writeLegacySave writes a version 1 save of a single chunk at the origin,
+// holding blocks by their ids in blockReg.
+func writeLegacySave(t *testing.T, dir string, blockReg BlockRegistry, blocks map[int]string) {
+	err := writeGzipFile(filepath.Join(dir, "level.dat"), func(wr io.Writer) error {
+		if err := writeVersionHeader(wr, levelMagic, 1); err != nil {
+			return err
+		}
+		if err := binary.Write(wr, binary.BigEndian, int64(7)); err != nil {
+			return err
+		}
+		if err := binary.Write(wr, binary.BigEndian, uint16(len(testBlockNames))); err != nil {
+			return err
+		}
+		for _, n := range testBlockNames {
+			if err := writeString(wr, n); err != nil {
+				return err
+			}
+			id := int16(blockReg.GetID(blockReg.ByName(n)))
+			if err := binary.Write(wr, binary.BigEndian, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int16, CHUNK_VOLUME)
+	for i, n := range blocks {
+		ids[i] = int16(blockReg.GetID(blockReg.ByName(n)))
+	}
+	err = writeGzipFile(filepath.Join(dir, regionFileName(Position{})), func(wr io.Writer) error {
+		if err := writeVersionHeader(wr, regionMagic, 1); err != nil {
+			return err
+		}
+		if err := binary.Write(wr, binary.BigEndian, uint16(1)); err != nil {
+			return err
+		}
+		if _, err := wr.Write([]byte{0, 0, 0, chunkStorageRaw}); err != nil {
+			return err
+		}
+		return binary.Write(wr, binary.BigEndian, ids)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadVersion1(t *testing.T) {
+	blocks := map[int]string{
+		chunkIndex(0, 0, 0):    "stone",
+		chunkIndex(1, 0, 0):    "gold_block",
+		chunkIndex(2, 3, 4):    "water",
+		chunkIndex(15, 15, 15): "leaves",
+	}
+	old := newTestRegistry(testBlockNames)
+	dir := t.TempDir()
+	writeLegacySave(t, dir, old, blocks)
+
+	// the ids have shifted since the save was made
+	names := append([]string{"bedrock", "glass"}, reversed(testBlockNames)...)
+	br := newTestRegistry(names)
+	if br.GetID(br.ByName("stone")) == old.GetID(old.ByName("stone")) {
+		t.Fatal("block ids did not shift")
+	}
+	w, err := LoadWorld(dir, br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.generator.Name() != "simplex" || w.generator.Seed() != 7 {
+		t.Errorf("generator %s, seed %d", w.generator.Name(), w.generator.Seed())
+	}
+	for i := 0; i < CHUNK_VOLUME; i++ {
+		x, y, z := i&CHUNK_MASK, i>>(2*CHUNK_SHIFT), (i>>CHUNK_SHIFT)&CHUNK_MASK
+		want := br.ByName(blocks[i])
+		if b := w.GetBlock(x, y, z); b != want {
+			t.Fatalf("block at %d, %d, %d: loaded %v, want %v", x, y, z, b, want)
+		}
+	}
+}
+
+func TestLoadVersion1UnknownBlock(t *testing.T) {
+	dir := t.TempDir()
+	writeLegacySave(t, dir, newTestRegistry(testBlockNames), map[int]string{0: "snow", 1: "stone"})
+
+	// snow is no longer registered
+	var names []string
+	for _, n := range testBlockNames {
+		if n != "snow" {
+			names = append(names, n)
+		}
+	}
+	w, err := LoadWorld(dir, newTestRegistry(names))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := w.GetBlock(0, 0, 0); b != nil {
+		t.Errorf("unknown block loaded as %v, want air", b)
+	}
+	if b := w.GetBlock(1, 0, 0); b == nil || b.Name() != "stone" {
+		t.Errorf("stone loaded as %v", b)
+	}
+}
+
+func TestLoadBadPalette(t *testing.T) {
+	br := newTestRegistry(testBlockNames)
+	c := &Chunk{}
+	c.set(0, br.ByName("stone"))
+	c.set(1, br.ByName("dirt"))
+	c.Compact()
+
+	read := func(palette []Block) error {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.BigEndian, uint16(len(palette)))
+		for _, b := range palette {
+			writeBlock(&buf, b)
+		}
+		buf.WriteByte(byte(c.indices.bits))
+		binary.Write(&buf, binary.BigEndian, c.indices.data)
+		return readChunkPaletted(&buf, &Chunk{}, br, SAVE_VERSION)
+	}
+	if err := read(c.palette); err != nil {
+		t.Fatalf("valid palette: %v", err)
+	}
+	// air moved away from index 0
+	swapped := []Block{c.palette[1], nil, c.palette[2]}
+	if err := read(swapped); err != ErrBadSave {
+		t.Errorf("palette without air first: got error %v, want %v", err, ErrBadSave)
+	}
+}
+
+func TestSaveWithoutGenerator(t *testing.T) {
+	br := newTestRegistry(testBlockNames)
+	w := NewWorldChunked(br, nil)
+	w.SetBlock(3, 4, 5, br.ByName("stone"))
+
+	dir := t.TempDir()
+	if err := SaveWorld(&w, dir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadWorld(dir, br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.generator.Name() != "void" {
+		t.Errorf("loaded generator %s, want void", loaded.generator.Name())
+	}
+	if b := loaded.GetBlock(3, 4, 5); b != br.ByName("stone") {
+		t.Errorf("block loaded as %v", b)
+	}
+	if c := loaded.LoadChunk(Position{5, 5, 5}); !c.IsEmpty() {
+		t.Error("new chunk generated with blocks")
+	}
+}
+
+// sameChunks compares the blocks and light of the chunks at ps in two
+// worlds, loading them into b.
+func sameChunks(t *testing.T, a *WorldChunked, b *WorldChunked, ps []Position) {
+	t.Helper()
+	for _, p := range ps {
+		b.LoadChunk(p)
+		for i := 0; i < CHUNK_VOLUME; i++ {
+			x, y, z := p.X<<CHUNK_SHIFT+i&CHUNK_MASK, p.Y<<CHUNK_SHIFT+i>>(2*CHUNK_SHIFT), p.Z<<CHUNK_SHIFT+(i>>CHUNK_SHIFT)&CHUNK_MASK
+			if ba, bb := a.GetBlock(x, y, z), b.GetBlock(x, y, z); !sameBlock(ba, bb) {
+				t.Fatalf("block at %d, %d, %d: %v, want %v", x, y, z, bb, ba)
+			}
+			sa, la := a.GetLight(x, y, z)
+			sb, lb := b.GetLight(x, y, z)
+			if sa != sb || la != lb {
+				t.Fatalf("light at %d, %d, %d: %d, %d, want %d, %d", x, y, z, sb, lb, sa, la)
+			}
+		}
+	}
+}
+
+func TestUnloadFar(t *testing.T) {
+	br := newTestRegistry(testBlockNames)
+	ref := NewWorldChunked(br, NewGeneratorSimplex(br, 7))
+	w := NewWorldChunked(br, NewGeneratorSimplex(br, 7))
+	dir := t.TempDir()
+	if err := w.SetSaveDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w.UnloadFar(0, 0, 0, 0); n != 0 || err != nil {
+		t.Fatalf("unloaded %d chunks of an empty world, %v", n, err)
+	}
+
+	for _, lw := range []*WorldChunked{&ref, &w} {
+		lw.LoadAround(0, 70, 0, 3, 100000)
+		lw.SetBlock(3, 70, 3, br.ByName("gold_block"))
+	}
+	loaded := len(w.chunks)
+	n, err := w.UnloadFar(100000, 70, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != loaded || len(w.chunks) != 0 || len(w.pending) != 0 {
+		t.Fatalf("unloaded %d of %d chunks, %d left, %d with pending writes", n, loaded, len(w.chunks), len(w.pending))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "level.dat")); err != nil {
+		t.Error(err)
+	}
+	if w.IsLoaded(3, 70, 3) {
+		t.Error("unloaded chunk still loaded")
+	}
+
+	// decorating the chunks next to the unloaded ones loads them back
+	for _, lw := range []*WorldChunked{&ref, &w} {
+		lw.LoadAround(16*5, 70, 0, 2, 100000)
+	}
+	if !w.IsLoaded(3, 70, 3) {
+		t.Error("chunk decorated into not loaded back")
+	}
+	sameChunks(t, &ref, &w, ref.PopulatedChunks())
+	if b := w.GetBlock(3, 70, 3); b == nil || b.Name() != "gold_block" {
+		t.Errorf("edited block loaded back as %v", b)
+	}
+}
+
+// chunkLoads records the chunks loaded into a world.
+type chunkLoads map[Position]int
+
+func (c chunkLoads) OnRenderUpdate(x int, y int, z int) {}
+
+func (c chunkLoads) OnChunkLoad(p Position) {
+	c[p]++
+}
+
+func TestUnloadDirty(t *testing.T) {
+	br := newTestRegistry(testBlockNames)
+	g, err := NewGeneratorFlat(br, 1, "stone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWorldChunked(br, g)
+	dir := t.TempDir()
+	if err := w.SetSaveDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	loads := make(chunkLoads)
+	w.RegisterRenderListener(loads)
+	name := filepath.Join(dir, regionFileName(Position{}))
+	unload := func() os.FileInfo {
+		t.Helper()
+		if _, err := w.UnloadFar(100000, 0, 0, 1); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi
+	}
+
+	p := Position{1, 0, 1}
+	w.LoadChunk(p)
+	saved := unload()
+	if w.LoadChunk(p).IsEmpty() || loads[p] != 2 {
+		t.Fatalf("chunk loaded back empty, or loaded %d times", loads[p])
+	}
+	if fi := unload(); !os.SameFile(saved, fi) {
+		t.Error("unchanged region saved again")
+	}
+
+	w.SetBlock(20, 0, 20, nil)
+	if fi := unload(); os.SameFile(saved, fi) {
+		t.Error("changed region not saved")
+	}
+	if b := w.GetBlock(20, 0, 20); b != nil {
+		t.Fatalf("unloaded block read as %v", b)
+	}
+	w.LoadChunk(p)
+	if b := w.GetBlock(20, 0, 20); b != nil {
+		t.Errorf("removed block loaded back as %v", b)
+	}
+	if b := w.GetBlock(21, 0, 20); b == nil {
+		t.Error("block loaded back as air")
+	}
+}
+
+func TestSaveUnloaded(t *testing.T) {
+	br := newTestRegistry(testBlockNames)
+	w := NewWorldChunked(br, nil)
+	dir := t.TempDir()
+	if err := w.SetSaveDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	w.SetBlock(0, 0, 0, br.ByName("stone"))
+	w.SetBlock(1000, 0, 0, br.ByName("dirt"))
+	if n, err := w.UnloadFar(1000, 0, 0, 1); n != 1 || err != nil {
+		t.Fatalf("unloaded %d chunks, %v", n, err)
+	}
+
+	// the unloaded region is left in place, or copied to another directory
+	for _, d := range []string{dir, t.TempDir()} {
+		if err := SaveWorld(&w, d); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadWorld(d, br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := loaded.GetBlock(0, 0, 0); b != br.ByName("stone") {
+			t.Errorf("%s: unloaded block loaded as %v", d, b)
+		}
+		if b := loaded.GetBlock(1000, 0, 0); b != br.ByName("dirt") {
+			t.Errorf("%s: resident block loaded as %v", d, b)
+		}
+	}
+}
+
+func TestUnloadBadRegion(t *testing.T) {
+	br := newTestRegistry(testBlockNames)
+	w := NewWorldChunked(br, nil)
+	dir := t.TempDir()
+	if err := w.SetSaveDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	w.SetBlock(0, 0, 0, br.ByName("stone"))
+	if _, err := w.UnloadFar(1000, 0, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, regionFileName(Position{}))
+	if err := os.WriteFile(name, []byte("junk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the region stays unloaded rather than being generated anew
+	w.SetBlock(1, 0, 0, br.ByName("dirt"))
+	if w.IsLoaded(0, 0, 0) {
+		t.Error("region failed to load but its chunk is loaded")
+	}
+	if _, err := w.UnloadFar(1000, 0, 0, 1); err == nil {
+		t.Error("failure to load the region not reported")
+	}
+	if data, err := os.ReadFile(name); err != nil || string(data) != "junk" {
+		t.Errorf("region file overwritten: %v", err)
+	}
+	if _, err := w.UnloadFar(1000, 0, 0, 1); err != nil {
+		t.Errorf("error reported twice: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package world
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// ParseSeed turns a user-provided seed into a number. Numbers are used as is;
+// any other text is hashed.
+func ParseSeed(s string) int64 {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return v
+	}
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return int64(h.Sum64())
+}
+
+// deriveSeed mixes a world seed with the name of a generation stage, so that
+// every stage gets its own reproducible source of randomness.
+func deriveSeed(seed int64, stage string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(stage))
+	// splitmix64 finalizer
+	z := uint64(seed) ^ h.Sum64()
+	z += 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// stageRand returns a random number generator for the given stage.
+func stageRand(seed int64, stage string) *rand.Rand {
+	return rand.New(rand.NewSource(deriveSeed(seed, stage)))
+}
+
+// chunkRand returns a random number generator for the given stage, specific
+// to a single chunk.
+func chunkRand(seed int64, stage string, p Position) *rand.Rand {
+	s := deriveSeed(seed, stage)
+	s = deriveSeed(s^int64(p.X)*0x1f1f1f1f, "x")
+	s = deriveSeed(s^int64(p.Y)*0x2e2e2e2e, "y")
+	s = deriveSeed(s^int64(p.Z)*0x3d3d3d3d, "z")
+	return rand.New(rand.NewSource(s))
+}
+
+// noiseOffset returns a coordinate offset for sampling noise in the given
+// stage. It is kept small enough for float64 noise input to stay precise.
+func noiseOffset(seed int64, stage string) float64 {
+	return float64(uint64(deriveSeed(seed, stage)) % (1 << 20))
+}
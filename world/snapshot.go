@@ -0,0 +1,117 @@
+package world
+
+// SNAPSHOT_SIZE is the edge length of a Snapshot: a chunk and the blocks
+// bordering it on every side.
+const SNAPSHOT_SIZE = CHUNK_SIZE + 2
+
+// Snapshot is a copy of the blocks and light of a chunk and of the blocks
+// bordering it, taken at one moment. It lets a chunk be meshed without
+// holding up writes to the world, or seeing them halfway. Writes to a
+// snapshot only change the copy.
+type Snapshot struct {
+	// origin is the position of the snapshot's lowest corner, one block
+	// below and behind that of the chunk
+	origin Position
+	blocks [SNAPSHOT_SIZE * SNAPSHOT_SIZE * SNAPSHOT_SIZE]Block
+	light  [SNAPSHOT_SIZE * SNAPSHOT_SIZE * SNAPSHOT_SIZE]uint8
+}
+
+// Snapshotter is implemented by worlds which can take a Snapshot of a
+// chunk by themselves, guarding it against concurrent writes.
+type Snapshotter interface {
+	Snapshot(p Position) *Snapshot
+}
+
+// TakeSnapshot copies the chunk at chunk position p and the blocks
+// bordering it out of w. Worlds which don't give access to light are fully
+// lit in the copy.
+func TakeSnapshot(w BlockAccess, p Position) *Snapshot {
+	if sw, ok := w.(Snapshotter); ok {
+		return sw.Snapshot(p)
+	}
+	la, _ := w.(LightAccess)
+	return newSnapshot(p, func(x int, y int, z int) (Block, uint8) {
+		if la == nil {
+			return w.GetBlock(x, y, z), MAX_LIGHT << LIGHT_SKY
+		}
+		sky, block := la.GetLight(x, y, z)
+		return w.GetBlock(x, y, z), sky<<LIGHT_SKY | block<<LIGHT_BLOCK
+	})
+}
+
+// Snapshot copies the chunk at chunk position p and the blocks bordering
+// it, under a single read lock.
+func (w *WorldChunked) Snapshot(p Position) *Snapshot {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	var lastPos Position
+	var last *Chunk
+	return newSnapshot(p, func(x int, y int, z int) (Block, uint8) {
+		cp := chunkPos(x, y, z)
+		if last == nil || cp != lastPos {
+			last, lastPos = w.chunks[cp], cp
+		}
+		var block Block
+		i := chunkIndex(x, y, z)
+		if last != nil {
+			block = last.get(i)
+		}
+		// as in getLight
+		if last != nil && last.lit {
+			return block, last.getLight(i, LIGHT_SKY)<<LIGHT_SKY | last.getLight(i, LIGHT_BLOCK)<<LIGHT_BLOCK
+		} else if skyOpen(y) {
+			return block, MAX_LIGHT << LIGHT_SKY
+		}
+		return block, 0
+	})
+}
+
+// newSnapshot fills a snapshot of the chunk at p with the blocks and
+// packed light levels returned by get.
+func newSnapshot(p Position, get func(int, int, int) (Block, uint8)) *Snapshot {
+	s := &Snapshot{origin: Position{p.X<<CHUNK_SHIFT - 1, p.Y<<CHUNK_SHIFT - 1, p.Z<<CHUNK_SHIFT - 1}}
+	i := 0
+	for y := 0; y < SNAPSHOT_SIZE; y++ {
+		for z := 0; z < SNAPSHOT_SIZE; z++ {
+			for x := 0; x < SNAPSHOT_SIZE; x++ {
+				s.blocks[i], s.light[i] = get(s.origin.X+x, s.origin.Y+y, s.origin.Z+z)
+				i++
+			}
+		}
+	}
+	return s
+}
+
+// index returns the index of the given position in the snapshot, or false
+// if it lies outside of it.
+func (s *Snapshot) index(x int, y int, z int) (int, bool) {
+	x, y, z = x-s.origin.X, y-s.origin.Y, z-s.origin.Z
+	if x < 0 || y < 0 || z < 0 || x >= SNAPSHOT_SIZE || y >= SNAPSHOT_SIZE || z >= SNAPSHOT_SIZE {
+		return 0, false
+	}
+	return (y*SNAPSHOT_SIZE+z)*SNAPSHOT_SIZE + x, true
+}
+
+// GetBlock returns the block at the given position, or nil outside of the
+// snapshot.
+func (s *Snapshot) GetBlock(x int, y int, z int) Block {
+	if i, ok := s.index(x, y, z); ok {
+		return s.blocks[i]
+	}
+	return nil
+}
+
+func (s *Snapshot) SetBlock(x int, y int, z int, block Block) {
+	if i, ok := s.index(x, y, z); ok {
+		s.blocks[i] = block
+	}
+}
+
+// GetLight returns the skylight and block light levels at the given
+// position. Outside of the snapshot it is dark.
+func (s *Snapshot) GetLight(x int, y int, z int) (uint8, uint8) {
+	if i, ok := s.index(x, y, z); ok {
+		return (s.light[i] >> LIGHT_SKY) & MAX_LIGHT, (s.light[i] >> LIGHT_BLOCK) & MAX_LIGHT
+	}
+	return 0, 0
+}
@@ -0,0 +1,239 @@
+package world
+
+// Property is a named block property with a fixed list of possible values.
+type Property struct {
+	name   string
+	values []string
+}
+
+var (
+	PropertyAxis = Property{"axis", []string{"y", "x", "z"}}
+	PropertyHalf = Property{"half", []string{"bottom", "top"}}
+)
+
+// BlockState packs the values of a block's properties into one integer, the
+// first property varying fastest.
+type BlockState uint16
+
+func (p Property) Name() string {
+	return p.name
+}
+
+func (p Property) index(value string) int {
+	for i, v := range p.values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// StateCount returns the number of distinct states for a set of properties.
+func StateCount(props []Property) int {
+	n := 1
+	for _, p := range props {
+		n *= len(p.values)
+	}
+	return n
+}
+
+func (s BlockState) Get(props []Property, name string) string {
+	v := int(s)
+	for _, p := range props {
+		if p.name == name {
+			return p.values[v%len(p.values)]
+		}
+		v /= len(p.values)
+	}
+	return ""
+}
+
+func (s BlockState) With(props []Property, name string, value string) BlockState {
+	mul := 1
+	for _, p := range props {
+		n := len(p.values)
+		if p.name == name {
+			i := p.index(value)
+			if i < 0 {
+				return s
+			}
+			cur := (int(s) / mul) % n
+			return BlockState(int(s) + (i-cur)*mul)
+		}
+		mul *= n
+	}
+	return s
+}
+
+// GetProperty returns the value of the named property of b, or an empty
+// string if b has no such property.
+func GetProperty(b Block, name string) string {
+	return b.State().Get(b.Properties(), name)
+}
+
+// WithProperty returns the state of b with the named property set to value.
+func WithProperty(b Block, name string, value string) Block {
+	return b.WithState(b.State().With(b.Properties(), name, value))
+}
+
+// BlockSlab is a half-height block occupying either the bottom or the top
+// half of its position.
+type BlockSlab struct {
+	name     string
+	textures [6]string
+	state    BlockState
+	states   []*BlockSlab
+	models   modelCache
+}
+
+var slabProperties = []Property{PropertyHalf}
+
+func NewBlockSlab(name string, textures [6]string) *BlockSlab {
+	states := make([]*BlockSlab, StateCount(slabProperties))
+	for i := range states {
+		states[i] = &BlockSlab{name: name, textures: textures, state: BlockState(i), states: states}
+	}
+	return states[0]
+}
+
+func (b *BlockSlab) isTop() bool {
+	return b.state.Get(slabProperties, "half") == "top"
+}
+
+func (b *BlockSlab) Name() string {
+	return b.name
+}
+
+func (b *BlockSlab) GetModel(a TextureAtlas) Model {
+	return b.models.get(a, func() Model {
+		var ta [6]Texture
+		for i := 0; i < 6; i++ {
+			ta[i] = a.Texture(b.textures[i])
+		}
+		bb := b.GetBoundingBox()
+		return NewCubeModel(ta, bb.Min, bb.Max)
+	})
+}
+
+func (b *BlockSlab) GetBoundingBox() BoundingBox {
+	if b.isTop() {
+		return BoundingBox{Vec3{0, 0.5, 0}, Vec3{1, 1, 1}}
+	} else {
+		return BoundingBox{Vec3{0, 0, 0}, Vec3{1, 0.5, 1}}
+	}
+}
+
+func (b *BlockSlab) IsSideSolid(d Direction) bool {
+	if b.isTop() {
+		return d == UP
+	} else {
+		return d == DOWN
+	}
+}
+
+func (b *BlockSlab) LightEmission() uint8 {
+	return 0
+}
+
+func (b *BlockSlab) RenderLayer() RenderLayer {
+	return LAYER_OPAQUE
+}
+
+func (b *BlockSlab) New() Block {
+	return b
+}
+
+func (b *BlockSlab) Properties() []Property {
+	return slabProperties
+}
+
+func (b *BlockSlab) State() BlockState {
+	return b.state
+}
+
+func (b *BlockSlab) WithState(s BlockState) Block {
+	if int(s) >= len(b.states) {
+		return b
+	}
+	return b.states[s]
+}
+
+// BlockLog is a log whose rings face along one of the three axes.
+type BlockLog struct {
+	name   string
+	top    string
+	side   string
+	state  BlockState
+	states []*BlockLog
+	models modelCache
+}
+
+var logProperties = []Property{PropertyAxis}
+
+func NewBlockLog(name string, top string, side string) *BlockLog {
+	states := make([]*BlockLog, StateCount(logProperties))
+	for i := range states {
+		states[i] = &BlockLog{name: name, top: top, side: side, state: BlockState(i), states: states}
+	}
+	return states[0]
+}
+
+func (b *BlockLog) Name() string {
+	return b.name
+}
+
+func (b *BlockLog) GetModel(a TextureAtlas) Model {
+	return b.models.get(a, func() Model {
+		var ta [6]Texture
+		for i := 0; i < 6; i++ {
+			ta[i] = a.Texture(b.side)
+		}
+		switch b.state.Get(logProperties, "axis") {
+		case "x":
+			ta[LEFT] = a.Texture(b.top)
+			ta[RIGHT] = a.Texture(b.top)
+		case "z":
+			ta[BACK] = a.Texture(b.top)
+			ta[FORWARD] = a.Texture(b.top)
+		default:
+			ta[DOWN] = a.Texture(b.top)
+			ta[UP] = a.Texture(b.top)
+		}
+		return NewFullCubeModel(ta)
+	})
+}
+
+func (b *BlockLog) GetBoundingBox() BoundingBox {
+	return BoundingBox{Vec3{0, 0, 0}, Vec3{1, 1, 1}}
+}
+
+func (b *BlockLog) IsSideSolid(d Direction) bool {
+	return true
+}
+
+func (b *BlockLog) LightEmission() uint8 {
+	return 0
+}
+
+func (b *BlockLog) RenderLayer() RenderLayer {
+	return LAYER_OPAQUE
+}
+
+func (b *BlockLog) New() Block {
+	return b
+}
+
+func (b *BlockLog) Properties() []Property {
+	return logProperties
+}
+
+func (b *BlockLog) State() BlockState {
+	return b.state
+}
+
+func (b *BlockLog) WithState(s BlockState) Block {
+	if int(s) >= len(b.states) {
+		return b
+	}
+	return b.states[s]
+}
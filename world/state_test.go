@@ -0,0 +1,94 @@
+package world
+
+import "testing"
+
+var testProperties = []Property{PropertyAxis, PropertyHalf, PropertyLevel}
+
+func TestBlockStateRoundTrip(t *testing.T) {
+	seen := make(map[BlockState]bool)
+	for _, axis := range PropertyAxis.values {
+		for _, half := range PropertyHalf.values {
+			for _, level := range PropertyLevel.values {
+				var s BlockState
+				s = s.With(testProperties, "level", level)
+				s = s.With(testProperties, "axis", axis)
+				s = s.With(testProperties, "half", half)
+				if got := s.Get(testProperties, "axis"); got != axis {
+					t.Fatalf("state %d: axis %q, want %q", s, got, axis)
+				}
+				if got := s.Get(testProperties, "half"); got != half {
+					t.Fatalf("state %d: half %q, want %q", s, got, half)
+				}
+				if got := s.Get(testProperties, "level"); got != level {
+					t.Fatalf("state %d: level %q, want %q", s, got, level)
+				}
+				if int(s) >= StateCount(testProperties) || seen[s] {
+					t.Fatalf("state %d out of range or used twice", s)
+				}
+				seen[s] = true
+			}
+		}
+	}
+
+	// setting a property leaves the others alone
+	s := BlockState(0).With(testProperties, "axis", "z").With(testProperties, "level", "5")
+	s = s.With(testProperties, "half", "top").With(testProperties, "axis", "x")
+	if s.Get(testProperties, "level") != "5" || s.Get(testProperties, "half") != "top" {
+		t.Errorf("state %d: level %s, half %s", s, s.Get(testProperties, "level"), s.Get(testProperties, "half"))
+	}
+}
+
+func TestBlockStateUnknown(t *testing.T) {
+	s := BlockState(0).With(testProperties, "axis", "x")
+	if got := s.With(testProperties, "axis", "w"); got != s {
+		t.Errorf("unknown value changed state %d to %d", s, got)
+	}
+	if got := s.With(testProperties, "open", "true"); got != s {
+		t.Errorf("unknown property changed state %d to %d", s, got)
+	}
+	if got := s.Get(testProperties, "open"); got != "" {
+		t.Errorf("unknown property read as %q", got)
+	}
+}
+
+// TestStateSaved saves every state of the blocks with properties and loads
+// them with the blocks registered in another order.
+func TestStateSaved(t *testing.T) {
+	br := newTestRegistry(testBlockNames)
+	g, err := NewGeneratorFlat(br, 1, "stone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWorldChunked(br, g)
+	var placed []Block
+	for _, n := range []string{"stone_slab", "log", "water"} {
+		b := br.ByName(n)
+		for s := 0; s < StateCount(b.Properties()); s++ {
+			w.SetBlock(len(placed), 0, 0, b.WithState(BlockState(s)))
+			placed = append(placed, w.GetBlock(len(placed), 0, 0))
+		}
+	}
+	if len(placed) != 2+3+16 {
+		t.Fatalf("placed %d states", len(placed))
+	}
+
+	dir := t.TempDir()
+	if err := SaveWorld(&w, dir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadWorld(dir, newTestRegistry(reversed(testBlockNames)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, b := range placed {
+		got := loaded.GetBlock(x, 0, 0)
+		if !sameBlock(got, b) {
+			t.Fatalf("block at %d: loaded %v, saved %v", x, got, b)
+		}
+		for _, p := range b.Properties() {
+			if GetProperty(got, p.Name()) != GetProperty(b, p.Name()) {
+				t.Errorf("block at %d: %s %s, saved %s", x, p.Name(), GetProperty(got, p.Name()), GetProperty(b, p.Name()))
+			}
+		}
+	}
+}
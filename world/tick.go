@@ -0,0 +1,189 @@
+package world
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+)
+
+// RANDOM_TICKS is the default number of random positions ticked in each
+// chunk per tick.
+const RANDOM_TICKS = 3
+
+// TickAccess is what ticking blocks see of the world: its blocks and
+// light, and the scheduler to ask for more ticks.
+type TickAccess interface {
+	World
+	LightAccess
+	// ScheduleTick asks for the block at the given position to be ticked
+	// after delay ticks, at least one. Of the ticks due at once, those
+	// with the lowest priority run first.
+	ScheduleTick(x int, y int, z int, delay int, priority int)
+}
+
+// ScheduledTicker is implemented by blocks which act on the ticks
+// scheduled for them.
+type ScheduledTicker interface {
+	ScheduledTick(w TickAccess, x int, y int, z int)
+}
+
+// RandomTicker is implemented by blocks which act now and then on their
+// own, such as grass spreading. Blocks are picked at random in every chunk
+// holding any blocks, RANDOM_TICKS times per tick by default.
+type RandomTicker interface {
+	RandomTick(w TickAccess, x int, y int, z int, r *rand.Rand)
+}
+
+// ChunkLister is implemented by worlds which can list their loaded chunks
+// holding any blocks, for the random ticks.
+type ChunkLister interface {
+	PopulatedChunks() []Position
+}
+
+// scheduledTick is a tick waiting in a Scheduler's queue. Ticks are
+// ordered by when they are due, then by priority, then by when they were
+// scheduled.
+type scheduledTick struct {
+	pos      Position
+	due      uint64
+	priority int
+	seq      uint64
+}
+
+func (t scheduledTick) before(o scheduledTick) bool {
+	if t.due != o.due {
+		return t.due < o.due
+	}
+	if t.priority != o.priority {
+		return t.priority < o.priority
+	}
+	return t.seq < o.seq
+}
+
+type tickQueue []scheduledTick
+
+func (q tickQueue) Len() int            { return len(q) }
+func (q tickQueue) Less(i, j int) bool  { return q[i].before(q[j]) }
+func (q tickQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *tickQueue) Push(x interface{}) { *q = append(*q, x.(scheduledTick)) }
+func (q *tickQueue) Pop() interface{} {
+	old := *q
+	t := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return t
+}
+
+// Scheduler ticks the blocks of a world: those which scheduled a tick,
+// once it is due, and blocks picked at random in the world's chunks. It
+// gives them access to the world as a TickAccess.
+//
+// A position has at most one scheduled tick; scheduling another keeps the
+// one which runs first. Scheduled ticks are not saved with the world.
+// Scheduler is not safe for concurrent use; the world must be changed and
+// ticked from one goroutine.
+type Scheduler struct {
+	World
+	// RandomTicks is the number of random positions ticked in each chunk
+	// per tick.
+	RandomTicks int
+	tick        uint64
+	seq         uint64
+	queue       tickQueue
+	// scheduled holds the tick queued for each position; other ticks for
+	// the position left in the queue are stale
+	scheduled map[Position]scheduledTick
+	rand      *rand.Rand
+}
+
+// tickAttacher is implemented by worlds which give their NeighborListeners
+// access to a Scheduler.
+type tickAttacher interface {
+	attachTicks(t TickAccess)
+}
+
+// NewScheduler creates a scheduler for w, picking the random ticks with a
+// generator seeded with seed. NeighborListeners in w schedule their ticks
+// with it.
+func NewScheduler(w World, seed int64) *Scheduler {
+	s := &Scheduler{
+		World:       w,
+		RandomTicks: RANDOM_TICKS,
+		scheduled:   make(map[Position]scheduledTick),
+		rand:        rand.New(rand.NewSource(seed)),
+	}
+	if a, ok := w.(tickAttacher); ok {
+		a.attachTicks(s)
+	}
+	return s
+}
+
+func (s *Scheduler) ScheduleTick(x int, y int, z int, delay int, priority int) {
+	if delay < 1 {
+		delay = 1
+	}
+	s.seq++
+	t := scheduledTick{Position{x, y, z}, s.tick + uint64(delay), priority, s.seq}
+	if old, exists := s.scheduled[t.pos]; exists && old.before(t) {
+		return
+	}
+	s.scheduled[t.pos] = t
+	heap.Push(&s.queue, t)
+}
+
+// Pending returns the number of scheduled ticks.
+func (s *Scheduler) Pending() int {
+	return len(s.scheduled)
+}
+
+// GetLight returns the light of the world, which is fully lit if it has
+// none.
+func (s *Scheduler) GetLight(x int, y int, z int) (uint8, uint8) {
+	if la, ok := s.World.(LightAccess); ok {
+		return la.GetLight(x, y, z)
+	}
+	return MAX_LIGHT, 0
+}
+
+// Tick advances the world by one tick, running the scheduled ticks which
+// are due, then the random ticks.
+func (s *Scheduler) Tick() {
+	s.tick++
+	for len(s.queue) > 0 && s.queue[0].due <= s.tick {
+		t := heap.Pop(&s.queue).(scheduledTick)
+		if s.scheduled[t.pos] != t {
+			continue
+		}
+		delete(s.scheduled, t.pos)
+		if b, ok := s.GetBlock(t.pos.X, t.pos.Y, t.pos.Z).(ScheduledTicker); ok {
+			b.ScheduledTick(s, t.pos.X, t.pos.Y, t.pos.Z)
+		}
+	}
+
+	pc, ok := s.World.(ChunkLister)
+	if !ok || s.RandomTicks <= 0 {
+		return
+	}
+	chunks := pc.PopulatedChunks()
+	// visit the chunks in a fixed order, so that a seed gives the same ticks
+	sort.Slice(chunks, func(i, j int) bool {
+		a, b := chunks[i], chunks[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		return a.X < b.X
+	})
+	for _, p := range chunks {
+		for i := 0; i < s.RandomTicks; i++ {
+			r := s.rand.Intn(CHUNK_VOLUME)
+			x := p.X<<CHUNK_SHIFT + r&CHUNK_MASK
+			z := p.Z<<CHUNK_SHIFT + (r>>CHUNK_SHIFT)&CHUNK_MASK
+			y := p.Y<<CHUNK_SHIFT + r>>(2*CHUNK_SHIFT)
+			if b, ok := s.GetBlock(x, y, z).(RandomTicker); ok {
+				b.RandomTick(s, x, y, z, s.rand)
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package world
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// tickRecorder logs the positions of its scheduled ticks.
+type tickRecorder struct {
+	*BlockSimple
+	log *[]Position
+}
+
+func (b *tickRecorder) ScheduledTick(w TickAccess, x int, y int, z int) {
+	*b.log = append(*b.log, Position{x, y, z})
+}
+
+func (b *tickRecorder) New() Block {
+	return b
+}
+
+// randomRecorder logs the positions of its random ticks.
+type randomRecorder struct {
+	*BlockSimple
+	log *[]Position
+}
+
+func (b *randomRecorder) RandomTick(w TickAccess, x int, y int, z int, r *rand.Rand) {
+	*b.log = append(*b.log, Position{x, y, z})
+}
+
+func (b *randomRecorder) New() Block {
+	return b
+}
+
+// newTickWorld returns a world with a row of tick recorders at x = 0 to
+// count-1, and a scheduler for it without random ticks.
+func newTickWorld(count int) (*WorldChunked, *Scheduler, *[]Position) {
+	w := NewWorldChunked(NewBlockRegistry(), nil)
+	log := new([]Position)
+	b := &tickRecorder{NewBlockSimple("recorder", [6]string{}), log}
+	for x := 0; x < count; x++ {
+		w.SetBlock(x, 0, 0, b)
+	}
+	s := NewScheduler(&w, 1)
+	s.RandomTicks = 0
+	return &w, s, log
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	_, s, log := newTickWorld(4)
+	s.ScheduleTick(0, 0, 0, 2, 5)
+	s.ScheduleTick(1, 0, 0, 2, -1)
+	s.ScheduleTick(2, 0, 0, 1, 9)
+	s.ScheduleTick(3, 0, 0, 2, 5)
+	s.Tick()
+	if want := []Position{{2, 0, 0}}; !reflect.DeepEqual(*log, want) {
+		t.Errorf("first tick ran %v, want %v", *log, want)
+	}
+	// lowest priority first, then in the order they were scheduled
+	*log = nil
+	s.Tick()
+	if want := []Position{{1, 0, 0}, {0, 0, 0}, {3, 0, 0}}; !reflect.DeepEqual(*log, want) {
+		t.Errorf("second tick ran %v, want %v", *log, want)
+	}
+	if s.Pending() != 0 {
+		t.Errorf("%d ticks left pending", s.Pending())
+	}
+}
+
+func TestSchedulerDedup(t *testing.T) {
+	_, s, log := newTickWorld(2)
+	s.ScheduleTick(0, 0, 0, 3, 0)
+	// a tick running later than the one already scheduled is dropped
+	s.ScheduleTick(0, 0, 0, 5, 0)
+	s.ScheduleTick(0, 0, 0, 3, 1)
+	// and one running earlier replaces it
+	s.ScheduleTick(1, 0, 0, 4, 0)
+	s.ScheduleTick(1, 0, 0, 2, 0)
+	if s.Pending() != 2 {
+		t.Errorf("%d ticks pending, want 2", s.Pending())
+	}
+	var ran [][]Position
+	for i := 0; i < 6; i++ {
+		*log = nil
+		s.Tick()
+		ran = append(ran, *log)
+	}
+	want := [][]Position{nil, {{1, 0, 0}}, {{0, 0, 0}}, nil, nil, nil}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("ticks ran %v, want %v", ran, want)
+	}
+}
+
+func TestSchedulerDelay(t *testing.T) {
+	w, s, log := newTickWorld(3)
+	s.ScheduleTick(0, 0, 0, 3, 0)
+	// delays under one tick are rounded up
+	s.ScheduleTick(1, 0, 0, 0, 0)
+	for i := 1; i <= 3; i++ {
+		*log = nil
+		s.Tick()
+		// ticks scheduled while ticking count from the current tick
+		if i == 1 {
+			s.ScheduleTick(2, 0, 0, 1, 0)
+		}
+		var want []Position
+		switch i {
+		case 1:
+			want = []Position{{1, 0, 0}}
+		case 2:
+			want = []Position{{2, 0, 0}}
+		case 3:
+			want = []Position{{0, 0, 0}}
+		}
+		if !reflect.DeepEqual(*log, want) {
+			t.Errorf("tick %d ran %v, want %v", i, *log, want)
+		}
+	}
+
+	// the block is looked up when the tick runs
+	*log = nil
+	s.ScheduleTick(0, 0, 0, 1, 0)
+	w.SetBlock(0, 0, 0, nil)
+	s.Tick()
+	if len(*log) != 0 || s.Pending() != 0 {
+		t.Errorf("removed block ticked: %v", *log)
+	}
+}
+
+// randomTicks returns the positions of the random ticks in n ticks of a
+// world of random tick recorders, seeded with seed.
+func randomTicks(seed int64, n int) []Position {
+	w := NewWorldChunked(NewBlockRegistry(), nil)
+	var log []Position
+	b := &randomRecorder{NewBlockSimple("recorder", [6]string{}), &log}
+	for _, p := range []Position{{0, 0, 0}, {-1, 2, 3}, {4, 0, -2}} {
+		for i := 0; i < CHUNK_VOLUME; i++ {
+			w.SetBlock(p.X<<CHUNK_SHIFT+i&CHUNK_MASK, p.Y<<CHUNK_SHIFT+i>>(2*CHUNK_SHIFT), p.Z<<CHUNK_SHIFT+(i>>CHUNK_SHIFT)&CHUNK_MASK, b)
+		}
+	}
+	// empty chunks aren't ticked
+	w.LoadChunk(Position{5, 5, 5})
+	s := NewScheduler(&w, seed)
+	for i := 0; i < n; i++ {
+		s.Tick()
+	}
+	return log
+}
+
+func TestRandomTicks(t *testing.T) {
+	a := randomTicks(1, 10)
+	if len(a) != 10*3*RANDOM_TICKS {
+		t.Errorf("%d random ticks, want %d", len(a), 10*3*RANDOM_TICKS)
+	}
+	if b := randomTicks(1, 10); !reflect.DeepEqual(a, b) {
+		t.Errorf("the same seed picked different blocks")
+	}
+	if b := randomTicks(2, 10); reflect.DeepEqual(a, b) {
+		t.Errorf("different seeds picked the same blocks")
+	}
+}
+
+// newGrassWorld returns a lit layer of dirt at y = MAP_H, with grass in
+// its middle, and a scheduler ticking every block of it in each tick.
+func newGrassWorld() (*WorldChunked, *Scheduler, *BlockGrass, Block, Block) {
+	w := NewWorldChunked(NewBlockRegistry(), nil)
+	dirt := NewBlockSimple("dirt", [6]string{})
+	grass := NewBlockGrass("grass", [6]string{}, dirt)
+	stone := NewBlockSimple("stone", [6]string{})
+	for x := 0; x < CHUNK_SIZE; x++ {
+		for z := 0; z < CHUNK_SIZE; z++ {
+			w.SetBlock(x, MAP_H, z, dirt)
+		}
+	}
+	w.SetBlock(8, MAP_H, 8, grass)
+	s := NewScheduler(&w, 3)
+	s.RandomTicks = CHUNK_VOLUME
+	return &w, s, grass, dirt, stone
+}
+
+func TestGrassSpreads(t *testing.T) {
+	w, s, grass, dirt, stone := newGrassWorld()
+	// covered dirt stays dirt
+	w.SetBlock(0, MAP_H+1, 0, stone)
+	// as does dirt below a dark space enclosed by dirt and stone
+	w.SetBlock(12, MAP_H, 12, nil)
+	w.SetBlock(12, MAP_H-1, 12, dirt)
+	for _, p := range []Position{{12, MAP_H + 1, 12}, {11, MAP_H + 1, 12}, {13, MAP_H + 1, 12}, {12, MAP_H + 1, 11}, {12, MAP_H + 1, 13}} {
+		w.SetBlock(p.X, p.Y, p.Z, stone)
+	}
+	for i := 0; i < 200; i++ {
+		s.Tick()
+	}
+
+	if b := w.GetBlock(0, MAP_H, 0); b != dirt {
+		t.Errorf("covered dirt turned into %v", b)
+	}
+	if b := w.GetBlock(12, MAP_H-1, 12); b != dirt {
+		t.Errorf("dirt in the dark turned into %v", b)
+	}
+	for x := 0; x < CHUNK_SIZE; x++ {
+		for z := 0; z < CHUNK_SIZE; z++ {
+			b := w.GetBlock(x, MAP_H, z)
+			if b != nil && b != Block(grass) && w.GetBlock(x, MAP_H+1, z) == nil {
+				t.Errorf("grass didn't spread to %d, %d", x, z)
+			}
+		}
+	}
+}
+
+func TestGrassDiesBack(t *testing.T) {
+	w, s, grass, dirt, stone := newGrassWorld()
+	slab := NewBlockSlab("slab", [6]string{})
+	top := slab.WithState(slab.State().With(slab.Properties(), "half", "top"))
+	w.SetBlock(8, MAP_H+1, 8, stone)
+	w.SetBlock(2, MAP_H, 2, grass)
+	// a top slab doesn't cover the grass under it
+	w.SetBlock(2, MAP_H+1, 2, top)
+	for i := 0; i < 100 && w.GetBlock(8, MAP_H, 8) != dirt; i++ {
+		s.Tick()
+	}
+	if b := w.GetBlock(8, MAP_H, 8); b != dirt {
+		t.Errorf("covered grass stayed %v", b)
+	}
+	if b := w.GetBlock(2, MAP_H, 2); b != Block(grass) {
+		t.Errorf("grass under a top slab turned into %v", b)
+	}
+}
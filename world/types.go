@@ -0,0 +1,40 @@
+package world
+
+type Direction int
+
+const (
+	DOWN Direction = iota
+	UP
+	LEFT
+	RIGHT
+	BACK
+	FORWARD
+	UNKNOWN
+)
+
+// directionOffsets maps each direction to the offset of the neighboring
+// position on that side.
+var directionOffsets = [6]Position{
+	{0, -1, 0}, {0, 1, 0},
+	{-1, 0, 0}, {1, 0, 0},
+	{0, 0, -1}, {0, 0, 1},
+}
+
+// Offset returns the offset of the neighboring position in direction d,
+// or the zero offset for UNKNOWN.
+func (d Direction) Offset() Position {
+	if d < DOWN || d > FORWARD {
+		return Position{}
+	}
+	return directionOffsets[d]
+}
+
+type Named interface {
+	Name() string
+}
+
+type Position struct {
+	X int
+	Y int
+	Z int
+}
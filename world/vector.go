@@ -0,0 +1,85 @@
+package world
+
+import (
+	"github.com/barnex/fmath"
+)
+
+type Vec2 [2]float32
+type Vec3 [3]float32
+
+func (v Vec3) Translate(v2 Vec3) Vec3 {
+	return Vec3{v[0] + v2[0], v[1] + v2[1], v[2] + v2[2]}
+}
+
+func (v Vec3) Scale(t float32) Vec3 {
+	return Vec3{v[0] * t, v[1] * t, v[2] * t}
+}
+
+// BlockPos returns the position of the block v is in.
+func (v Vec3) BlockPos() Position {
+	return Position{int(fmath.Floor(v[0])), int(fmath.Floor(v[1])), int(fmath.Floor(v[2]))}
+}
+
+func (v Vec2) Translate(v2 Vec2) Vec2 {
+	return Vec2{v[0] + v2[0], v[1] + v2[1]}
+}
+
+func (v Vec2) Scale(t float32) Vec2 {
+	return Vec2{v[0] * t, v[1] * t}
+}
+
+type BoundingBox struct {
+	Min Vec3
+	Max Vec3
+}
+
+// COLLISION_EPSILON is how far boxes may overlap before they are taken to
+// collide, so that rounding doesn't catch a box sliding along another.
+const COLLISION_EPSILON = 1.0 / 1024
+
+// Intersects reports whether b and b2 overlap. Boxes which only touch
+// don't.
+func (b BoundingBox) Intersects(b2 BoundingBox) bool {
+	for i := 0; i < 3; i++ {
+		if b.Min[i] >= b2.Max[i] || b2.Min[i] >= b.Max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Expand stretches b to cover everything it passes through when moved by v.
+func (b BoundingBox) Expand(v Vec3) BoundingBox {
+	for i := 0; i < 3; i++ {
+		if v[i] < 0 {
+			b.Min[i] += v[i]
+		} else {
+			b.Max[i] += v[i]
+		}
+	}
+	return b
+}
+
+// Clip returns how far b can move by up to d along axis before hitting
+// b2. Boxes which don't overlap across the other two axes can move freely.
+func (b BoundingBox) Clip(b2 BoundingBox, axis int, d float32) float32 {
+	for i := 0; i < 3; i++ {
+		if i != axis && (b.Min[i] >= b2.Max[i]-COLLISION_EPSILON || b2.Min[i] >= b.Max[i]-COLLISION_EPSILON) {
+			return d
+		}
+	}
+	if d > 0 && b.Max[axis] <= b2.Min[axis]+COLLISION_EPSILON {
+		if gap := b2.Min[axis] - b.Max[axis]; gap < d {
+			d = gap
+		}
+	} else if d < 0 && b.Min[axis] >= b2.Max[axis]-COLLISION_EPSILON {
+		if gap := b2.Max[axis] - b.Min[axis]; gap > d {
+			d = gap
+		}
+	}
+	return d
+}
+
+func (b BoundingBox) Translate(v Vec3) BoundingBox {
+	return BoundingBox{b.Min.Translate(v), b.Max.Translate(v)}
+}
@@ -0,0 +1,80 @@
+package world
+
+import (
+	"testing"
+)
+
+func box(x0, y0, z0, x1, y1, z1 float32) BoundingBox {
+	return BoundingBox{Vec3{x0, y0, z0}, Vec3{x1, y1, z1}}
+}
+
+func TestBoundingBoxIntersects(t *testing.T) {
+	unit := box(0, 0, 0, 1, 1, 1)
+	cases := []struct {
+		b    BoundingBox
+		want bool
+	}{
+		{box(0.5, 0.5, 0.5, 2, 2, 2), true},
+		{box(-1, -1, -1, 3, 3, 3), true},
+		{box(0.2, 0.2, 0.2, 0.3, 0.3, 0.3), true},
+		{box(0, 0.5, 0, 1, 5, 1), true},
+		// boxes of different sizes whose mins are far apart
+		{box(0.9, 0, 0, 5, 1, 1), true},
+		{box(-3, 0, 0, 0.1, 1, 1), true},
+		// touching boxes don't intersect
+		{box(1, 0, 0, 2, 1, 1), false},
+		{box(0, -1, 0, 1, 0, 1), false},
+		{box(1, 1, 1, 2, 2, 2), false},
+		{box(1.5, 0, 0, 2, 1, 1), false},
+		{box(0, 2, 0, 1, 3, 1), false},
+		{box(0.5, 0.5, 1.5, 2, 2, 2), false},
+	}
+	for _, c := range cases {
+		if got := unit.Intersects(c.b); got != c.want {
+			t.Errorf("unit.Intersects(%v) = %v, want %v", c.b, got, c.want)
+		}
+		if got := c.b.Intersects(unit); got != c.want {
+			t.Errorf("%v.Intersects(unit) = %v, want %v", c.b, got, c.want)
+		}
+	}
+}
+
+func TestBoundingBoxExpand(t *testing.T) {
+	unit := box(0, 0, 0, 1, 1, 1)
+	if got, want := unit.Expand(Vec3{-1, 2, 0}), box(-1, 0, 0, 1, 3, 1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBoundingBoxClip(t *testing.T) {
+	unit := box(0, 0, 0, 1, 1, 1)
+	wall := box(2, 0, 0, 3, 1, 1)
+	cases := []struct {
+		name string
+		b    BoundingBox
+		axis int
+		d    float32
+		want float32
+	}{
+		{"stopped by the wall", wall, 0, 5, 1},
+		{"short of the wall", wall, 0, 0.5, 0.5},
+		{"moving away", wall, 0, -5, -5},
+		{"touching, moving into it", box(1, 0, 0, 2, 1, 1), 0, 1, 0},
+		{"touching, moving away", box(1, 0, 0, 2, 1, 1), 0, -1, -1},
+		{"above the path", box(2, 1, 0, 3, 2, 1), 0, 5, 5},
+		{"falling onto a slab", box(0, -2, 0, 1, -0.5, 1), 1, -3, -0.5},
+		{"hitting the ceiling", box(0, 3, 0, 1, 4, 1), 1, 4, 2},
+		// sliding along a floor rounded just into it isn't stopped
+		{"along a floor within epsilon", box(1, -1, 0, 2, COLLISION_EPSILON/2, 1), 0, 1, 1},
+		{"along a floor past epsilon", box(1, -1, 0, 2, 2*COLLISION_EPSILON, 1), 0, 1, 0},
+		// a box rounded just into another is pushed back out
+		{"just inside", box(1-COLLISION_EPSILON/2, 0, 0, 2, 1, 1), 0, 1, -COLLISION_EPSILON / 2},
+		// boxes already well inside each other don't stop movement
+		{"deep inside", box(0.5, 0, 0, 1.5, 1, 1), 0, 1, 1},
+	}
+	for _, c := range cases {
+		if got := unit.Clip(c.b, c.axis, c.d); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package world
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/barnex/fmath"
+)
+
+// InViewDistance reports whether the chunk at chunk position p is within
+// radius chunks of the chunk center: inside the circle of that radius
+// around it horizontally, and at most radius chunks above or below it.
+func InViewDistance(center Position, p Position, radius int) bool {
+	dx, dy, dz := p.X-center.X, p.Y-center.Y, p.Z-center.Z
+	return dx*dx+dz*dz <= radius*radius && dy >= -radius && dy <= radius
+}
+
+var (
+	viewOffsetsLock sync.Mutex
+	viewOffsets     = make(map[int][]Position)
+)
+
+// ViewOffsets returns the offsets of the chunks within radius chunks of a
+// center chunk, as in InViewDistance, nearest first. The slice is shared
+// and must not be modified.
+func ViewOffsets(radius int) []Position {
+	viewOffsetsLock.Lock()
+	defer viewOffsetsLock.Unlock()
+	if offsets, ok := viewOffsets[radius]; ok {
+		return offsets
+	}
+	var offsets []Position
+	for dy := -radius; dy <= radius; dy++ {
+		for dz := -radius; dz <= radius; dz++ {
+			for dx := -radius; dx <= radius; dx++ {
+				p := Position{dx, dy, dz}
+				if InViewDistance(Position{}, p, radius) {
+					offsets = append(offsets, p)
+				}
+			}
+		}
+	}
+	sort.SliceStable(offsets, func(i, j int) bool {
+		return offsets[i].lengthSq() < offsets[j].lengthSq()
+	})
+	viewOffsets[radius] = offsets
+	return offsets
+}
+
+func (p Position) lengthSq() int {
+	return p.X*p.X + p.Y*p.Y + p.Z*p.Z
+}
+
+// ViewPriority returns the sort key of the chunk at the given offset from
+// the viewer's chunk, lower keys coming first: its distance, less up to
+// half of it for chunks in the direction forward, which is a unit vector
+// or zero.
+func ViewPriority(offset Position, forward Vec3) float32 {
+	d := Vec3{float32(offset.X), float32(offset.Y), float32(offset.Z)}
+	length := fmath.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+	ahead := d[0]*forward[0] + d[1]*forward[1] + d[2]*forward[2]
+	return length - ahead/2
+}
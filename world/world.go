@@ -0,0 +1,27 @@
+package world
+
+const MAP_H = 128
+
+type World interface {
+	IsValid(int, int, int) bool
+	IsLoaded(int, int, int) bool
+	GetBlock(int, int, int) Block
+	SetBlock(int, int, int, Block)
+	GetBiome(int, int) *Biome
+}
+
+type BlockAccess interface {
+	GetBlock(int, int, int) Block
+	SetBlock(int, int, int, Block)
+}
+
+// LightAccess gives access to the skylight and block light levels of a
+// world, between 0 and MAX_LIGHT.
+type LightAccess interface {
+	GetLight(int, int, int) (uint8, uint8)
+}
+
+type RenderListener interface {
+	OnRenderUpdate(int, int, int)
+	OnChunkLoad(Position)
+}